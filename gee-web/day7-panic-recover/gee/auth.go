@@ -0,0 +1,64 @@
+package gee
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+)
+
+// AuthUserKey 认证通过后，用户名保存在Context中的key，可以用c.GetString(AuthUserKey)读取
+const AuthUserKey = "user"
+
+// Accounts 用户名到密码的映射
+type Accounts map[string]string
+
+type authPair struct {
+	value string // Authorization头部完整的值，例如 Basic Z2VlOjEyMw==
+	user  string
+}
+
+// BasicAuth HTTP基本认证，realm为 Authorization Required
+func BasicAuth(accounts Accounts) HandlerFunc {
+	return BasicAuthForRealm(accounts, "")
+}
+
+/*
+	BasicAuthForRealm 按realm进行HTTP基本认证，认证失败返回401和WWW-Authenticate头部
+	提前算好每个账号的Authorization头部，比较时用常量时间，避免从响应时间猜出密码
+*/
+func BasicAuthForRealm(accounts Accounts, realm string) HandlerFunc {
+	if len(accounts) == 0 {
+		panic("gee: empty list of authorized credentials")
+	}
+	if realm == "" {
+		realm = "Authorization Required"
+	}
+	challenge := "Basic realm=" + strconv.Quote(realm)
+	pairs := make([]authPair, 0, len(accounts))
+	for user, password := range accounts {
+		if user == "" {
+			panic("gee: user can not be empty")
+		}
+		credentials := base64.StdEncoding.EncodeToString([]byte(user + ":" + password))
+		pairs = append(pairs, authPair{value: "Basic " + credentials, user: user})
+	}
+
+	return func(c *Context) {
+		header := c.Req.Header.Get("Authorization")
+		user, found := "", false
+		for _, pair := range pairs {
+			// 遍历所有账号，不提前退出
+			if subtle.ConstantTimeCompare([]byte(header), []byte(pair.value)) == 1 {
+				user, found = pair.user, true
+			}
+		}
+		if !found {
+			c.SetHeader("WWW-Authenticate", challenge)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Set(AuthUserKey, user)
+		c.Next()
+	}
+}
@@ -0,0 +1,96 @@
+package gee
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBasicAuth(t *testing.T) {
+	r := New()
+	r.Use(BasicAuthForRealm(Accounts{"geektutu": "123", "gee": "456"}, "admin"))
+	r.GET("/admin", func(c *Context) {
+		c.String(http.StatusOK, c.GetString(AuthUserKey))
+	})
+
+	req := httptest.NewRequest("GET", "/admin", nil)
+	req.SetBasicAuth("geektutu", "123")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "geektutu" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/admin", nil)
+	req.SetBasicAuth("geektutu", "456")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != `Basic realm="admin"` {
+		t.Fatalf("unexpected response %d %v", w.Code, w.Header())
+	}
+}
+
+func signJWT(secret string, header, claims H) string {
+	encode := func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	unsigned := encode(header) + "." + encode(claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestJWT(t *testing.T) {
+	r := New()
+	r.Use(JWTWithConfig(JWTConfig{Secret: []byte("secret"), Issuer: "gee", Audience: "api", Realm: "gee"}))
+	r.GET("/me", func(c *Context) {
+		claims := c.MustGet(JWTClaimsKey).(JWTClaims)
+		c.JSON(http.StatusOK, H{"user": c.GetString(AuthUserKey), "role": claims["role"]})
+	})
+
+	now := time.Now().Unix()
+	hs256 := H{"alg": "HS256", "typ": "JWT"}
+	valid := H{"sub": "geektutu", "role": "admin", "iss": "gee", "aud": []string{"web", "api"}, "exp": now + 60}
+	cases := []struct {
+		token string
+		code  int
+		body  string
+	}{
+		{signJWT("secret", hs256, valid), http.StatusOK, `{"role":"admin","user":"geektutu"}`},
+		{"", http.StatusUnauthorized, "missing bearer token"},
+		{"abc.def", http.StatusUnauthorized, "malformed"},
+		{signJWT("other", hs256, valid), http.StatusUnauthorized, "signature"},
+		{signJWT("secret", H{"alg": "none"}, valid), http.StatusUnauthorized, "algorithm"},
+		{signJWT("secret", hs256, H{"iss": "gee", "aud": "api", "exp": now - 10}), http.StatusUnauthorized, "expired"},
+		{signJWT("secret", hs256, H{"iss": "gee", "aud": "api", "nbf": now + 60}), http.StatusUnauthorized, "not valid yet"},
+		{signJWT("secret", hs256, H{"iss": "other", "aud": "api"}), http.StatusUnauthorized, "issuer"},
+		{signJWT("secret", hs256, H{"iss": "gee", "aud": "web"}), http.StatusUnauthorized, "audience"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("GET", "/me", nil)
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.code || !strings.Contains(w.Body.String(), tc.body) {
+			t.Fatalf("token %q: unexpected response %d %q", tc.token, w.Code, w.Body.String())
+		}
+		if tc.code == http.StatusUnauthorized && !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), `Bearer realm="gee"`) {
+			t.Fatalf("missing WWW-Authenticate header, got %q", w.Header().Get("WWW-Authenticate"))
+		}
+	}
+
+	// leeway允许少量的时钟误差
+	conf := JWTConfig{Secret: []byte("secret"), Leeway: time.Minute}
+	if _, err := ParseJWT(signJWT("secret", hs256, H{"exp": now - 10}), conf, time.Now()); err != nil {
+		t.Fatalf("token within leeway should be valid, got %v", err)
+	}
+}
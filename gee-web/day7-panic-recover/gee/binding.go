@@ -0,0 +1,273 @@
+package gee
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 常用的Content-Type
+const (
+	MIMEJSON              = "application/json"
+	MIMEHTML              = "text/html"
+	MIMEXML               = "application/xml"
+	MIMEXML2              = "text/xml"
+	MIMEPlain             = "text/plain"
+	MIMEJavaScript        = "application/javascript"
+	MIMEYAML              = "application/x-yaml"
+	MIMEPROTOBUF          = "application/x-protobuf"
+	MIMEPOSTForm          = "application/x-www-form-urlencoded"
+	MIMEMultipartPOSTForm = "multipart/form-data"
+)
+
+// Engine.MaxMultipartMemory的默认值
+const defaultMultipartMemory = 32 << 20 // 32 MB
+
+/*
+	请求绑定：把请求中的数据解码到结构体，再按binding标签校验
+	字段名取自结构体标签：JSON用json标签，表单和query用form标签，路由参数用uri标签
+	例如
+	type Login struct {
+		User     string `form:"user" json:"user" binding:"required,min=3"`
+		Password string `form:"password" json:"password" binding:"required"`
+	}
+*/
+
+// ShouldBind 根据请求方法和Content-Type选择解码方式，出错时返回error，由调用者决定如何响应
+// GET等没有请求体的方法从query解码，JSON请求体用json解码，其余按表单解码
+func (c *Context) ShouldBind(obj interface{}) error {
+	if c.Method == http.MethodGet || c.Method == http.MethodHead || c.Method == http.MethodDelete {
+		return c.ShouldBindQuery(obj)
+	}
+	switch c.ContentType() {
+	case MIMEJSON:
+		return c.ShouldBindJSON(obj)
+	default:
+		return c.ShouldBindForm(obj)
+	}
+}
+
+// Bind 和ShouldBind一样，出错时中止后面的handler并返回400
+// 校验失败时响应中的errors字段列出每个字段的错误
+func (c *Context) Bind(obj interface{}) error {
+	err := c.ShouldBind(obj)
+	if err != nil {
+		body := H{"message": err.Error()}
+		var verrs ValidationErrors
+		if errors.As(err, &verrs) {
+			body["errors"] = verrs
+		}
+		c.AbortWithStatusJSON(http.StatusBadRequest, body)
+	}
+	return err
+}
+
+// ShouldBindJSON 把JSON请求体解码到obj并校验
+func (c *Context) ShouldBindJSON(obj interface{}) error {
+	if c.Req.Body == nil {
+		return errors.New("gee: empty request body")
+	}
+	if err := json.NewDecoder(c.Req.Body).Decode(obj); err != nil {
+		return err
+	}
+	return Validate(obj)
+}
+
+// ShouldBindQuery 按form标签把URL中?后面的参数解码到obj并校验
+func (c *Context) ShouldBindQuery(obj interface{}) error {
+	if err := mapValues(obj, c.Req.URL.Query(), "form"); err != nil {
+		return err
+	}
+	return Validate(obj)
+}
+
+// ShouldBindForm 按form标签把表单(包括multipart表单)和query参数解码到obj并校验
+func (c *Context) ShouldBindForm(obj interface{}) error {
+	if c.ContentType() == MIMEMultipartPOSTForm {
+		if err := c.Req.ParseMultipartForm(c.engine.MaxMultipartMemory); err != nil {
+			return err
+		}
+	} else if err := c.Req.ParseForm(); err != nil {
+		return err
+	}
+	if err := mapValues(obj, c.Req.Form, "form"); err != nil {
+		return err
+	}
+	return Validate(obj)
+}
+
+// ShouldBindUri 按uri标签把路由参数解码到obj并校验，例如 /user/:id 对应 `uri:"id"`
+func (c *Context) ShouldBindUri(obj interface{}) error {
+	values := make(map[string][]string, len(c.Params))
+	for _, p := range c.Params {
+		values[p.Key] = []string{p.Value}
+	}
+	if err := mapValues(obj, values, "uri"); err != nil {
+		return err
+	}
+	return Validate(obj)
+}
+
+// ContentType 返回请求的Content-Type，不包括 ; 后面的参数
+func (c *Context) ContentType() string {
+	contentType := c.Req.Header.Get("Content-Type")
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return strings.TrimSpace(contentType)
+}
+
+// 按tag把values中的值写入obj指向的结构体，没有tag的字段用字段名，tag为"-"的字段跳过
+func mapValues(obj interface{}, values map[string][]string, tag string) error {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return errors.New("gee: binding requires a non-nil pointer to a struct")
+	}
+	return mapStruct(v.Elem(), values, tag)
+}
+
+func mapStruct(v reflect.Value, values map[string][]string, tag string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous { // 未导出的字段
+			continue
+		}
+		name := field.Tag.Get(tag)
+		if name == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		// 没有tag的嵌套结构体，展开继续绑定
+		if name == "" && fv.Kind() == reflect.Struct && fv.Type() != timeType {
+			if err := mapStruct(fv, values, tag); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		vs, ok := values[name]
+		if !ok || !fv.CanSet() {
+			continue
+		}
+		if err := setField(fv, field, vs); err != nil {
+			return fmt.Errorf("gee: binding field '%s': %v", name, err)
+		}
+	}
+	return nil
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// 给字段赋值，切片字段接收全部的值，其余只取第一个值
+func setField(fv reflect.Value, field reflect.StructField, vs []string) error {
+	switch fv.Kind() {
+	case reflect.Slice:
+		slice := reflect.MakeSlice(fv.Type(), len(vs), len(vs))
+		for i, s := range vs {
+			if err := setValue(slice.Index(i), field, s); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	case reflect.Ptr:
+		elem := reflect.New(fv.Type().Elem())
+		if err := setField(elem.Elem(), field, vs); err != nil {
+			return err
+		}
+		fv.Set(elem)
+		return nil
+	}
+	if len(vs) == 0 {
+		return nil
+	}
+	return setValue(fv, field, vs[0])
+}
+
+// 把字符串转换成字段的类型，time.Time 默认按RFC3339解析，可以用time_format标签指定格式
+func setValue(fv reflect.Value, field reflect.StructField, s string) error {
+	switch fv.Type() {
+	case timeType:
+		if s == "" {
+			return nil
+		}
+		layout := field.Tag.Get("time_format")
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		if s == "" {
+			s = "false"
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if s == "" {
+			s = "0"
+		}
+		n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if s == "" {
+			s = "0"
+		}
+		n, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		if s == "" {
+			s = "0"
+		}
+		f, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case reflect.Ptr:
+		elem := reflect.New(fv.Type().Elem())
+		if err := setValue(elem.Elem(), field, s); err != nil {
+			return err
+		}
+		fv.Set(elem)
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package gee
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type loginForm struct {
+	User     string   `form:"user" json:"user" binding:"required,min=3,max=10"`
+	Password string   `form:"password" json:"password" binding:"required,len=6"`
+	Role     string   `form:"role" json:"role" binding:"oneof=admin guest"`
+	Code     string   `form:"code" json:"code" binding:"regex=[a-z]{2},[0-9]+"`
+	Tags     []string `form:"tag" json:"tags" binding:"max=2"`
+	Age      int      `form:"age" json:"age" binding:"min=18"`
+}
+
+func TestShouldBindQuery(t *testing.T) {
+	c := &Context{params: new(Params)}
+	req := httptest.NewRequest("GET", "/login?user=geek&password=123456&tag=a&tag=b&age=20&code=ab,12", nil)
+	c.reset(httptest.NewRecorder(), req)
+	var form loginForm
+	if err := c.ShouldBind(&form); err != nil {
+		t.Fatal(err)
+	}
+	if form.User != "geek" || form.Age != 20 || len(form.Tags) != 2 || form.Code != "ab,12" {
+		t.Fatalf("unexpected binding result %+v", form)
+	}
+}
+
+func TestShouldBindJSONValidation(t *testing.T) {
+	c := &Context{params: new(Params)}
+	body := `{"user":"ge","role":"root","tags":["a","b","c"],"age":3,"code":"abc"}`
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	c.reset(httptest.NewRecorder(), req)
+
+	var form loginForm
+	err := c.ShouldBind(&form)
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("should return ValidationErrors, got %v", err)
+	}
+	want := map[string]string{"user": "min", "password": "required", "role": "oneof", "tags": "max", "age": "min", "code": "regex"}
+	if len(verrs) != len(want) {
+		t.Fatalf("unexpected errors %v", verrs)
+	}
+	for _, fe := range verrs {
+		if want[fe.Field] != fe.Rule {
+			t.Fatalf("unexpected error %+v", fe)
+		}
+	}
+}
+
+func TestShouldBindUriAndForm(t *testing.T) {
+	type userURI struct {
+		ID int `uri:"id" binding:"required"`
+	}
+	type userForm struct {
+		Name string `form:"name" binding:"required"`
+	}
+	r := New()
+	r.POST("/user/:id", func(c *Context) {
+		var uri userURI
+		var form userForm
+		if err := c.ShouldBindUri(&uri); err != nil {
+			c.String(http.StatusBadRequest, "uri: %v", err)
+			return
+		}
+		if err := c.ShouldBind(&form); err != nil {
+			c.String(http.StatusBadRequest, "form: %v", err)
+			return
+		}
+		c.String(http.StatusOK, "%d %s", uri.ID, form.Name)
+	})
+	req := httptest.NewRequest("POST", "/user/42", strings.NewReader("name=geektutu"))
+	req.Header.Set("Content-Type", MIMEPOSTForm)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "42 geektutu" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/user/abc", nil))
+	if w.Code != http.StatusBadRequest || !strings.HasPrefix(w.Body.String(), "uri:") {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,135 @@
+package gee
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+/*
+	路由参数的约束，写在参数名后面的<>中，例如
+	/users/:id<int>         只匹配整数
+	/files/:uuid<uuid>      只匹配UUID
+	/posts/:slug<[a-z-]+>   其它的按正则表达式整体匹配，表达式中不能有 /
+	同一位置可以注册多个约束不同的参数，不匹配时回溯到下一个候选
+*/
+type paramConstraint struct {
+	text  string // <>中的内容
+	match func(value string) bool
+}
+
+func (pc *paramConstraint) String() string {
+	if pc == nil {
+		return ""
+	}
+	return pc.text
+}
+
+// 把 :id<int> 解析成参数名id和约束int，没有约束时constraint为nil
+func parseParam(text string, pattern string) (key string, constraint *paramConstraint) {
+	key = text[1:]
+	i := strings.IndexByte(key, '<')
+	if i < 0 {
+		return key, nil
+	}
+	expr := key[i+1:]
+	key = key[:i]
+	if key == "" {
+		panic(fmt.Sprintf("gee: wildcard in route '%s' must be named", pattern))
+	}
+	if !strings.HasSuffix(expr, ">") || len(expr) == 1 {
+		panic(fmt.Sprintf("gee: constraint of '%s' in route '%s' must end with '>' and can not contain '/'", text, pattern))
+	}
+	expr = expr[:len(expr)-1]
+	switch expr {
+	case "int":
+		return key, &paramConstraint{text: expr, match: isInt}
+	case "uuid":
+		return key, &paramConstraint{text: expr, match: isUUID}
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		panic(fmt.Sprintf("gee: invalid constraint of '%s' in route '%s': %v", text, pattern, err))
+	}
+	return key, &paramConstraint{text: expr, match: re.MatchString}
+}
+
+// 可以带负号的十进制整数，是否溢出由Context.ParamInt检查
+func isInt(s string) bool {
+	if s != "" && s[0] == '-' {
+		s = s[1:]
+	}
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isUUID(s string) bool {
+	_, ok := parseUUID(s)
+	return ok
+}
+
+// UUID 是RFC 4122格式的UUID，例如 6ba7b810-9dad-11d1-80b4-00c04fd430c8
+type UUID [16]byte
+
+// ParseUUID 解析xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx格式的UUID，不区分大小写
+func ParseUUID(s string) (UUID, error) {
+	u, ok := parseUUID(s)
+	if !ok {
+		return u, fmt.Errorf("gee: invalid UUID %q", s)
+	}
+	return u, nil
+}
+
+func parseUUID(s string) (u UUID, ok bool) {
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, false
+	}
+	j := 0
+	for i := 0; i < len(s); i += 2 {
+		if s[i] == '-' {
+			i++
+		}
+		hi, ok1 := fromHexChar(s[i])
+		lo, ok2 := fromHexChar(s[i+1])
+		if !ok1 || !ok2 {
+			return u, false
+		}
+		u[j] = hi<<4 | lo
+		j++
+	}
+	return u, true
+}
+
+func fromHexChar(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+func (u UUID) String() string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
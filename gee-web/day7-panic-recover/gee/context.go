@@ -1,9 +1,19 @@
 package gee
 
 import (
-	"encoding/json"
 	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 type H map[string]interface{}
@@ -11,31 +21,81 @@ type H map[string]interface{}
 // 定义 Context
 type Context struct {
 	// origin objects
-	Writer http.ResponseWriter
+	// Writer 包装了原始的http.ResponseWriter，可以查询状态码和是否已经写入
+	Writer ResponseWriter
 	Req    *http.Request
 	// request info
 	Path   string
 	Method string
 	// 提供对路由参数的访问。我们将解析后的参数存储到Params中，通过c.Param("lang")的方式获取到对应的值。
-	Params map[string]string
+	// Params的底层数组是Context复用的，请求结束后会被下一个请求覆盖
+	Params Params
 	// response info
+	// Deprecated: 使用 c.Writer.Status()。StatusCode在Status和Next返回时和Writer同步，
+	// handler直接调用c.Writer.WriteHeader之后，要等Next返回才会更新
 	StatusCode int
+	// 匹配到的路由，例如 /p/:lang/doc
+	fullPath string
 	// 中间件
 	index    int
 	handlers []HandlerFunc
 
-	engine *Engine
+	// Keys 是每个请求独有的键值对，中间件可以通过Set把数据(例如登录的用户)传给handler
+	// 读写需要通过Set、Get等方法，它们有锁保护，可以在handler启动的goroutine中使用
+	Keys map[string]interface{}
+	mu   sync.RWMutex
+
+	engine    *Engine
+	params    *Params        // 查找路由时用来存放参数的缓冲区，随Context一起复用
+	writermem responseWriter // Writer指向它，随Context一起复用
 }
 
-// Context 的构造函数
-func newContext(w http.ResponseWriter, req *http.Request) *Context {
-	return &Context{
-		Writer: w,
-		Req:    req,
-		Path:   req.URL.Path,
-		Method: req.Method,
-		index:  -1,
+// Abort后index被设为abortIndex，Next中的循环就不会再执行后面的handler
+const abortIndex = math.MaxInt16
+
+// Context 从池中取出后，重置成一个新请求的状态
+func (c *Context) reset(w http.ResponseWriter, req *http.Request) {
+	c.writermem.reset(w)
+	c.Writer = &c.writermem
+	c.Req = req
+	c.Path = req.URL.Path
+	c.Method = req.Method
+	c.Params = nil
+	c.StatusCode = 0
+	c.fullPath = ""
+	c.index = -1
+	c.handlers = nil
+	c.Keys = nil
+	*c.params = (*c.params)[:0]
+}
+
+// Copy 返回当前Context的一个副本，handler中启动的goroutine需要使用Context时，必须使用副本。
+// 副本不能用来写响应，也不会执行中间件
+func (c *Context) Copy() *Context {
+	cp := &Context{
+		Req:        c.Req,
+		Path:       c.Path,
+		Method:     c.Method,
+		StatusCode: c.StatusCode,
+		fullPath:   c.fullPath,
+		engine:     c.engine,
+	}
+	cp.writermem = c.writermem
+	cp.writermem.ResponseWriter = nil
+	cp.Writer = &cp.writermem
+	params := make(Params, len(c.Params))
+	copy(params, c.Params)
+	cp.Params = params
+	cp.params = &params
+	c.mu.RLock()
+	if c.Keys != nil {
+		cp.Keys = make(map[string]interface{}, len(c.Keys))
+		for k, v := range c.Keys {
+			cp.Keys[k] = v
+		}
 	}
+	c.mu.RUnlock()
+	return cp
 }
 
 func (c *Context) Next() {
@@ -44,16 +104,249 @@ func (c *Context) Next() {
 	for ; c.index < s; c.index++ { // 遍历这些中间件
 		c.handlers[c.index](c)
 	}
+	c.StatusCode = c.Writer.Status()
+}
+
+// Abort 中止后面的handler，当前handler会继续执行完
+// 例如认证失败的中间件调用Abort，后面的handler就不会被执行
+func (c *Context) Abort() {
+	c.index = abortIndex
+}
+
+// IsAborted 返回是否已经中止
+func (c *Context) IsAborted() bool {
+	return c.index >= abortIndex
+}
+
+// AbortWithStatus 中止并立即发送状态码，没有响应体
+func (c *Context) AbortWithStatus(code int) {
+	c.Status(code)
+	c.Writer.WriteHeaderNow()
+	c.Abort()
 }
 
+// AbortWithStatusJSON 中止并返回json
+func (c *Context) AbortWithStatusJSON(code int, obj interface{}) {
+	c.Abort()
+	c.JSON(code, obj)
+}
+
+// Fail 中止后面的handler并返回错误信息
+// 响应头已经发送时无法再修改状态码，只中止处理
 func (c *Context) Fail(code int, err string) {
-	c.index = len(c.handlers)
+	c.Abort()
+	if c.Writer.Written() {
+		return
+	}
 	c.JSON(code, H{"message": err})
 }
 
+// Set 保存一个只属于当前请求的键值对
+func (c *Context) Set(key string, value interface{}) {
+	c.mu.Lock()
+	if c.Keys == nil {
+		c.Keys = make(map[string]interface{})
+	}
+	c.Keys[key] = value
+	c.mu.Unlock()
+}
+
+// Get 返回key对应的值，以及key是否存在
+func (c *Context) Get(key string) (value interface{}, exists bool) {
+	c.mu.RLock()
+	value, exists = c.Keys[key]
+	c.mu.RUnlock()
+	return
+}
+
+// MustGet 返回key对应的值，key不存在时panic
+func (c *Context) MustGet(key string) interface{} {
+	if value, exists := c.Get(key); exists {
+		return value
+	}
+	panic("gee: key \"" + key + "\" does not exist")
+}
+
+// 下面的GetXxx返回指定类型的值，key不存在或者类型不对时返回零值
+
+func (c *Context) GetString(key string) (s string) {
+	if val, ok := c.Get(key); ok && val != nil {
+		s, _ = val.(string)
+	}
+	return
+}
+
+func (c *Context) GetBool(key string) (b bool) {
+	if val, ok := c.Get(key); ok && val != nil {
+		b, _ = val.(bool)
+	}
+	return
+}
+
+func (c *Context) GetInt(key string) (i int) {
+	if val, ok := c.Get(key); ok && val != nil {
+		i, _ = val.(int)
+	}
+	return
+}
+
+func (c *Context) GetInt64(key string) (i64 int64) {
+	if val, ok := c.Get(key); ok && val != nil {
+		i64, _ = val.(int64)
+	}
+	return
+}
+
+func (c *Context) GetUint(key string) (ui uint) {
+	if val, ok := c.Get(key); ok && val != nil {
+		ui, _ = val.(uint)
+	}
+	return
+}
+
+func (c *Context) GetUint64(key string) (ui64 uint64) {
+	if val, ok := c.Get(key); ok && val != nil {
+		ui64, _ = val.(uint64)
+	}
+	return
+}
+
+func (c *Context) GetFloat64(key string) (f64 float64) {
+	if val, ok := c.Get(key); ok && val != nil {
+		f64, _ = val.(float64)
+	}
+	return
+}
+
+func (c *Context) GetTime(key string) (t time.Time) {
+	if val, ok := c.Get(key); ok && val != nil {
+		t, _ = val.(time.Time)
+	}
+	return
+}
+
+func (c *Context) GetDuration(key string) (d time.Duration) {
+	if val, ok := c.Get(key); ok && val != nil {
+		d, _ = val.(time.Duration)
+	}
+	return
+}
+
+func (c *Context) GetStringSlice(key string) (ss []string) {
+	if val, ok := c.Get(key); ok && val != nil {
+		ss, _ = val.([]string)
+	}
+	return
+}
+
+func (c *Context) GetStringMap(key string) (sm map[string]interface{}) {
+	if val, ok := c.Get(key); ok && val != nil {
+		sm, _ = val.(map[string]interface{})
+	}
+	return
+}
+
+func (c *Context) GetStringMapString(key string) (sms map[string]string) {
+	if val, ok := c.Get(key); ok && val != nil {
+		sms, _ = val.(map[string]string)
+	}
+	return
+}
+
+/*
+	Context实现了context.Context，可以直接传给需要context.Context的函数，
+	截止时间和取消信号来自请求的context，Value先查Keys，再查请求的context
+*/
+
+// Deadline 返回请求context的截止时间
+func (c *Context) Deadline() (deadline time.Time, ok bool) {
+	if c.Req == nil {
+		return
+	}
+	return c.Req.Context().Deadline()
+}
+
+// Done 请求被取消或者超时时关闭，例如客户端断开了连接
+func (c *Context) Done() <-chan struct{} {
+	if c.Req == nil {
+		return nil
+	}
+	return c.Req.Context().Done()
+}
+
+// Err 返回请求context被取消的原因
+func (c *Context) Err() error {
+	if c.Req == nil {
+		return nil
+	}
+	return c.Req.Context().Err()
+}
+
+// Value key是字符串时先从Keys中查找，再从请求的context中查找
+func (c *Context) Value(key interface{}) interface{} {
+	if keyAsString, ok := key.(string); ok {
+		if val, exists := c.Get(keyAsString); exists {
+			return val
+		}
+	}
+	if c.Req == nil {
+		return nil
+	}
+	return c.Req.Context().Value(key)
+}
+
 func (c *Context) Param(key string) string {
-	value, _ := c.Params[key]
-	return value
+	return c.Params.ByName(key)
+}
+
+// ParamInt 把路由参数转换成int，参数不存在或者不是整数时返回错误，配合 :id<int> 使用
+func (c *Context) ParamInt(key string) (int, error) {
+	value, ok := c.Params.Get(key)
+	if !ok {
+		return 0, fmt.Errorf("gee: param '%s' not found", key)
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("gee: param '%s' is not an integer: %v", key, err)
+	}
+	return n, nil
+}
+
+// ParamUUID 把路由参数解析成UUID，参数不存在或者格式不对时返回错误，配合 :id<uuid> 使用
+func (c *Context) ParamUUID(key string) (UUID, error) {
+	value, ok := c.Params.Get(key)
+	if !ok {
+		return UUID{}, fmt.Errorf("gee: param '%s' not found", key)
+	}
+	return ParseUUID(value)
+}
+
+// FullPath 返回匹配到的路由，例如 /p/:lang/doc，没有匹配到路由时返回空字符串
+func (c *Context) FullPath() string {
+	return c.fullPath
+}
+
+// ClientIP 返回客户端的IP
+// Engine.ForwardedByClientIP 为true时依次从X-Forwarded-For、X-Real-IP中获取，
+// 这两个头可以被客户端伪造，只有部署在可信的代理后面时才应该打开
+func (c *Context) ClientIP() string {
+	if c.engine.ForwardedByClientIP {
+		if forwarded := c.Req.Header.Get("X-Forwarded-For"); forwarded != "" {
+			if i := strings.IndexByte(forwarded, ','); i >= 0 {
+				forwarded = forwarded[:i]
+			}
+			if ip := strings.TrimSpace(forwarded); ip != "" {
+				return ip
+			}
+		}
+		if ip := strings.TrimSpace(c.Req.Header.Get("X-Real-IP")); ip != "" {
+			return ip
+		}
+	}
+	if ip, _, err := net.SplitHostPort(strings.TrimSpace(c.Req.RemoteAddr)); err == nil {
+		return ip
+	}
+	return c.Req.RemoteAddr
 }
 
 // 表单数据
@@ -62,17 +355,59 @@ func (c *Context) PostForm(key string) string {
 	return c.Req.FormValue(key)
 }
 
+// FormFile 返回multipart表单中name对应的第一个文件
+func (c *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	if c.Req.MultipartForm == nil {
+		if err := c.Req.ParseMultipartForm(c.engine.MaxMultipartMemory); err != nil {
+			return nil, err
+		}
+	}
+	f, fh, err := c.Req.FormFile(name)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return fh, nil
+}
+
+// MultipartForm 解析multipart表单，包括上传的文件，最多使用Engine.MaxMultipartMemory的内存
+func (c *Context) MultipartForm() (*multipart.Form, error) {
+	err := c.Req.ParseMultipartForm(c.engine.MaxMultipartMemory)
+	return c.Req.MultipartForm, err
+}
+
+// SaveUploadedFile 把上传的文件保存到dst，目录不存在时自动创建
+func (c *Context) SaveUploadedFile(file *multipart.FileHeader, dst string) error {
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err = os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
 // query是指请求的参数，一般是指URL中？后面的参数
 func (c *Context) Query(key string) string {
 	return c.Req.URL.Query().Get(key)
 }
 
 // 设置状态码
+// 只是记录下来，第一次写入响应体或者请求处理完时才发送响应头，在此之前都可以修改
 func (c *Context) Status(code int) {
-	c.StatusCode = code
-	// WriteHeader sends an HTTP response header with the provided
-	// status code.
 	c.Writer.WriteHeader(code)
+	c.StatusCode = c.Writer.Status()
 }
 
 // 设置头部的key value对
@@ -81,39 +416,261 @@ func (c *Context) SetHeader(key string, value string) {
 	c.Writer.Header().Set(key, value)
 }
 
+// Render 写入状态码和Content-Type，再用r写入响应体
+// 1xx、204、304的响应不能有响应体，只写状态码
+// 渲染失败时返回500
+func (c *Context) Render(code int, r Render) {
+	r.WriteContentType(c.Writer)
+	c.Status(code)
+	if !bodyAllowedForStatus(code) {
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	if err := r.Render(c.Writer); err != nil {
+		if !c.Writer.Written() {
+			// 还没有写出内容，去掉r设置的Content-Type，返回干净的500
+			c.Writer.Header().Del("Content-Type")
+		}
+		c.Fail(http.StatusInternalServerError, err.Error())
+	}
+}
+
+// 和net/http的规则一致
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent:
+		return false
+	case status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
+
 // 返回数据为text/plain类型的response
 // 这里需要自定义的format 以便可以使用任意类型的value，类似于泛型的设计
 func (c *Context) String(code int, format string, values ...interface{}) {
-	c.SetHeader("Content-Type", "text/plain")
-	c.Status(code)
-	// 正确的调用顺序应该是Header().Set 然后WriteHeader() 最后是Write()
-	c.Writer.Write([]byte(fmt.Sprintf(format, values...))) // Sprintf返回的是一个string
+	c.Render(code, String{Format: format, Data: values})
 }
 
-// 响应的数据为json格式
-func (c *Context) JSON(code int, obj ...interface{}) {
-	c.SetHeader("Content-Type", "application/json")
-	c.Status(code)
-	//
-	encoder := json.NewEncoder(c.Writer) // c.Writer是json数据的接收者
-	if err := encoder.Encode(obj); err != nil {
-		http.Error(c.Writer, err.Error(), 500)
+// 响应的数据为json格式，obj本身被编码，例如 c.JSON(200, H{"name": "gee"})
+func (c *Context) JSON(code int, obj interface{}) {
+	c.Render(code, JSON{Data: obj})
+}
+
+// IndentedJSON 响应带缩进的json，比JSON多占一些带宽，适合调试
+func (c *Context) IndentedJSON(code int, obj interface{}) {
+	c.Render(code, IndentedJSON{Data: obj})
+}
+
+// SecureJSON 响应json，obj是数组时加上Engine.SecureJSONPrefix前缀
+func (c *Context) SecureJSON(code int, obj interface{}) {
+	c.Render(code, SecureJSON{Prefix: c.engine.SecureJSONPrefix, Data: obj})
+}
+
+// JSONP 响应json，query中有callback参数时包装成 callback(json);
+// callback不是合法的JS标识符时返回400
+func (c *Context) JSONP(code int, obj interface{}) {
+	callback := c.Query("callback")
+	if callback != "" && !validJSONPCallback(callback) {
+		c.Fail(http.StatusBadRequest, ErrInvalidCallback.Error())
+		return
 	}
+	c.Render(code, JSONP{Callback: callback, Data: obj})
+}
+
+// XML 响应xml
+func (c *Context) XML(code int, obj interface{}) {
+	c.Render(code, XML{Data: obj})
+}
+
+// YAML 响应yaml
+func (c *Context) YAML(code int, obj interface{}) {
+	c.Render(code, YAML{Data: obj})
+}
+
+// ProtoBuf 响应protobuf，obj必须是proto.Message
+func (c *Context) ProtoBuf(code int, obj interface{}) {
+	c.Render(code, ProtoBuf{Data: obj})
 }
 
 // response写入数据,这些数据是字符数据
 func (c *Context) Data(code int, data []byte) {
-	c.Status(code)
-	c.Writer.Write(data)
+	c.Render(code, Data{Data: data})
 }
 
 // 构造HTML响应
 // 根据模板文件名选择模板进行渲染
 func (c *Context) HTML(code int, name string, data interface{}) {
-	c.SetHeader("Content-Type", "text/html")
-	c.Status(code)
-	//c.Writer.Write([]byte(html))
-	if err:=c.engine.htmlTemplates.ExecuteTemplate(c.Writer,name,data);err!=nil{
-			c.Fail(500,err.Error())
+	var r Render = HTML{Name: name, Data: data}
+	if c.engine.HTMLRender != nil {
+		r = c.engine.HTMLRender.Instance(name, data)
+	}
+	c.Render(code, r)
+}
+
+// File 发送本地文件，由http.ServeFile处理Range、If-Modified-Since等请求头
+func (c *Context) File(filepath string) {
+	http.ServeFile(c.Writer, c.Req, filepath)
+}
+
+// FileFromFS 从fs中发送文件，filepath是fs中的路径，同样支持Range
+func (c *Context) FileFromFS(filepath string, fs http.FileSystem) {
+	defer func(old string) {
+		c.Req.URL.Path = old
+	}(c.Req.URL.Path)
+
+	c.Req.URL.Path = filepath
+	http.FileServer(fs).ServeHTTP(c.Writer, c.Req)
+}
+
+// FileAttachment 以附件的形式发送文件，浏览器会按filename下载而不是直接打开
+func (c *Context) FileAttachment(filepath, filename string) {
+	c.SetHeader("Content-Disposition", contentDisposition(filename))
+	http.ServeFile(c.Writer, c.Req, filepath)
+}
+
+/*
+	filename中有非ASCII字符时，旧的浏览器只认filename，新的浏览器优先使用filename*(RFC 5987)
+	例如 报告.pdf 输出为 attachment; filename="__.pdf"; filename*=UTF-8''%E6%8A%A5%E5%91%8A.pdf
+*/
+func contentDisposition(filename string) string {
+	var ascii strings.Builder
+	isASCII := true
+	for _, r := range filename {
+		switch {
+		case r >= 0x80 || r < 0x20 || r == 0x7f:
+			isASCII = false
+			ascii.WriteByte('_')
+		case r == '"' || r == '\\':
+			ascii.WriteByte('\\')
+			ascii.WriteRune(r)
+		default:
+			ascii.WriteRune(r)
+		}
+	}
+	value := `attachment; filename="` + ascii.String() + `"`
+	if isASCII {
+		return value
+	}
+	var ext strings.Builder
+	for i := 0; i < len(filename); i++ {
+		b := filename[i]
+		if isAttrChar(b) {
+			ext.WriteByte(b)
+		} else {
+			fmt.Fprintf(&ext, "%%%02X", b)
+		}
+	}
+	return value + "; filename*=UTF-8''" + ext.String()
+}
+
+// RFC 5987中不需要转义的字符
+func isAttrChar(b byte) bool {
+	if 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' {
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+}
+
+// Negotiate 是内容协商的配置，Offered是服务端能提供的格式，按优先级排列
+// HTMLName是渲染HTML时使用的模板，HTMLData为空时使用Data
+type Negotiate struct {
+	Offered  []string
+	Data     interface{}
+	HTMLName string
+	HTMLData interface{}
+}
+
+// Negotiate 根据Accept头从config.Offered中选择格式渲染，没有可接受的格式时返回406
+func (c *Context) Negotiate(code int, config Negotiate) {
+	switch c.NegotiateFormat(config.Offered...) {
+	case MIMEJSON:
+		c.JSON(code, config.Data)
+	case MIMEXML, MIMEXML2:
+		c.XML(code, config.Data)
+	case MIMEYAML:
+		c.YAML(code, config.Data)
+	case MIMEPROTOBUF:
+		c.ProtoBuf(code, config.Data)
+	case MIMEPlain:
+		c.String(code, "%v", config.Data)
+	case MIMEHTML:
+		data := config.HTMLData
+		if data == nil {
+			data = config.Data
+		}
+		c.HTML(code, config.HTMLName, data)
+	default:
+		c.Fail(http.StatusNotAcceptable, "the accepted formats are not offered by the server")
+	}
+}
+
+// NegotiateFormat 按Accept头中的权重，返回offered中第一个可以接受的格式，都不能接受时返回空字符串
+// 没有Accept头时返回offered[0]
+func (c *Context) NegotiateFormat(offered ...string) string {
+	if len(offered) == 0 {
+		panic("gee: you must provide at least one offer")
+	}
+	accepted := parseAccept(c.Req.Header.Get("Accept"))
+	if len(accepted) == 0 {
+		return offered[0]
+	}
+	for _, accept := range accepted {
+		for _, offer := range offered {
+			if acceptMatch(accept, offer) {
+				return offer
+			}
+		}
+	}
+	return ""
+}
+
+// 按q值从大到小排列Accept中的类型，q=0表示不接受
+// 例如 text/html, application/json;q=0.9, */*;q=0.1
+func parseAccept(header string) []string {
+	type accept struct {
+		mime string
+		q    float64
+	}
+	var accepts []accept
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		mime := strings.TrimSpace(fields[0])
+		if mime == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			accepts = append(accepts, accept{mime, q})
+		}
+	}
+	sort.SliceStable(accepts, func(i, j int) bool {
+		return accepts[i].q > accepts[j].q
+	})
+	mimes := make([]string, len(accepts))
+	for i, a := range accepts {
+		mimes[i] = a.mime
+	}
+	return mimes
+}
+
+// Accept中的类型可以是 */* 或者 text/* 这样的通配
+func acceptMatch(accept string, offer string) bool {
+	if accept == "*/*" || accept == offer {
+		return true
+	}
+	if strings.HasSuffix(accept, "/*") {
+		return strings.HasPrefix(offer, accept[:len(accept)-1])
 	}
+	return false
 }
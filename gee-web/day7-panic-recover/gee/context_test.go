@@ -0,0 +1,292 @@
+package gee
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestContextCopy(t *testing.T) {
+	r := New()
+	copies := make(chan *Context, 1)
+	r.GET("/hello/:name", func(c *Context) {
+		copies <- c.Copy()
+		c.String(http.StatusOK, "hello %s", c.Param("name"))
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/hello/geektutu", nil))
+	cp := <-copies
+
+	// 原来的Context回到池中被下一个请求复用，副本的参数不受影响
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/hello/gee", nil))
+	<-copies
+	if cp.Param("name") != "geektutu" || cp.Path != "/hello/geektutu" {
+		t.Fatalf("copy should keep its own params, got %q %q", cp.Param("name"), cp.Path)
+	}
+}
+
+func TestContextAbort(t *testing.T) {
+	r := New()
+	var reached []string
+	r.Use(func(c *Context) {
+		c.Next()
+		reached = append(reached, "logger")
+		if !c.IsAborted() {
+			t.Error("context should be aborted")
+		}
+	})
+	r.Use(func(c *Context) {
+		if c.Query("token") == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, H{"message": "unauthorized"})
+			return
+		}
+		c.Set("user", "geektutu")
+		c.Next()
+	})
+	r.GET("/secret", func(c *Context) {
+		reached = append(reached, "handler")
+		c.String(http.StatusOK, c.GetString("user"))
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/secret", nil))
+	if w.Code != http.StatusUnauthorized || w.Body.String() != `{"message":"unauthorized"}` {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+	if len(reached) != 1 || reached[0] != "logger" {
+		t.Fatalf("handler shouldn't be reached, got %v", reached)
+	}
+}
+
+func TestContextStatusCode(t *testing.T) {
+	r := New()
+	var codes []int
+	r.Use(func(c *Context) {
+		c.Next()
+		codes = append(codes, c.StatusCode)
+	})
+	r.GET("/status", func(c *Context) {
+		c.Status(http.StatusAccepted)
+		codes = append(codes, c.StatusCode)
+	})
+	r.GET("/writer", func(c *Context) {
+		// 直接写Writer，Next返回之后StatusCode也要同步
+		c.Writer.WriteHeader(http.StatusCreated)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/status", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/writer", nil))
+	expected := []int{http.StatusAccepted, http.StatusAccepted, http.StatusCreated}
+	if !reflect.DeepEqual(codes, expected) {
+		t.Fatalf("StatusCode should be %v, got %v", expected, codes)
+	}
+}
+
+func TestContextKeys(t *testing.T) {
+	c := &Context{}
+	c.Set("user", "geektutu")
+	c.Set("age", 18)
+	c.Set("roles", []string{"admin"})
+	if c.GetString("user") != "geektutu" || c.GetInt("age") != 18 || len(c.GetStringSlice("roles")) != 1 {
+		t.Fatal("unexpected values")
+	}
+	// 类型不对时返回零值
+	if c.GetInt("user") != 0 || c.GetString("none") != "" {
+		t.Fatal("mismatched type should return zero value")
+	}
+	if _, ok := c.Get("none"); ok {
+		t.Fatal("none shouldn't exist")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("MustGet should panic")
+		}
+	}()
+	c.MustGet("none")
+}
+
+func TestContextKeysConcurrent(t *testing.T) {
+	c := &Context{}
+	done := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		go func(i int) {
+			c.Set("key", i)
+			c.GetInt("key")
+			done <- struct{}{}
+		}(i)
+	}
+	for i := 0; i < 10; i++ {
+		<-done
+	}
+}
+
+func TestContextValue(t *testing.T) {
+	r := New()
+	r.GET("/value", func(c *Context) {
+		c.Set("user", "geektutu")
+		if c.Value("user") != "geektutu" || c.Value("none") != nil || c.Err() != nil {
+			t.Error("unexpected context values")
+		}
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/value", nil))
+}
+
+func TestContextUpload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := New()
+	r.MaxMultipartMemory = 1 << 10
+	r.POST("/upload", func(c *Context) {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.Fail(http.StatusBadRequest, err.Error())
+			return
+		}
+		form, _ := c.MultipartForm()
+		dst := filepath.Join(dir, form.Value["dir"][0], file.Filename)
+		if err := c.SaveUploadedFile(file, dst); err != nil {
+			c.Fail(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.String(http.StatusOK, "%s %d", file.Filename, file.Size)
+	})
+
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+	mw.WriteField("dir", "docs")
+	fw, _ := mw.CreateFormFile("file", "gee.txt")
+	content := bytes.Repeat([]byte("gee"), 1<<10)
+	fw.Write(content)
+	mw.Close()
+
+	req := httptest.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "gee.txt 3072" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+	saved, err := ioutil.ReadFile(filepath.Join(dir, "docs", "gee.txt"))
+	if err != nil || !bytes.Equal(saved, content) {
+		t.Fatalf("uploaded file is not saved, %v", err)
+	}
+
+	req = httptest.NewRequest("POST", "/upload", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("request without file should fail, got %d", w.Code)
+	}
+}
+
+func TestContextFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "gee.txt"), []byte("hello gee"), 0644)
+
+	r := New()
+	r.GET("/file", func(c *Context) {
+		c.File(filepath.Join(dir, "gee.txt"))
+	})
+	r.GET("/fs", func(c *Context) {
+		c.FileFromFS("/gee.txt", http.Dir(dir))
+	})
+	r.GET("/attachment", func(c *Context) {
+		c.FileAttachment(filepath.Join(dir, "gee.txt"), c.Query("name"))
+	})
+
+	for _, path := range []string{"/file", "/fs"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Range", "bytes=6-")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusPartialContent || w.Body.String() != "gee" || w.Header().Get("Content-Range") != "bytes 6-8/9" {
+			t.Fatalf("%s: unexpected response %d %q", path, w.Code, w.Body.String())
+		}
+	}
+
+	cases := map[string]string{
+		"gee.txt":  `attachment; filename="gee.txt"`,
+		`a"b.txt`:  `attachment; filename="a\"b.txt"`,
+		"报告 1.txt": `attachment; filename="__ 1.txt"; filename*=UTF-8''%E6%8A%A5%E5%91%8A%201.txt`,
+	}
+	for name, disposition := range cases {
+		req := httptest.NewRequest("GET", "/attachment?name="+url.QueryEscape(name), nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Disposition") != disposition {
+			t.Fatalf("unexpected Content-Disposition %q", w.Header().Get("Content-Disposition"))
+		}
+	}
+}
+
+func TestContextTypedParams(t *testing.T) {
+	r := New()
+	r.GET("/users/:id/:uuid", func(c *Context) {
+		id, err := c.ParamInt("id")
+		if err != nil {
+			c.Fail(http.StatusBadRequest, err.Error())
+			return
+		}
+		uuid, err := c.ParamUUID("uuid")
+		if err != nil {
+			c.Fail(http.StatusBadRequest, err.Error())
+			return
+		}
+		if _, err := c.ParamInt("none"); err == nil {
+			t.Error("missing param should return an error")
+		}
+		c.String(http.StatusOK, "%d %s", id, uuid)
+	})
+
+	cases := map[string]string{
+		"/users/42/6BA7B810-9DAD-11D1-80B4-00C04FD430C8": "42 6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		"/users/x/6ba7b810-9dad-11d1-80b4-00c04fd430c8":  `{"message":"gee: param 'id' is not an integer: strconv.Atoi: parsing \"x\": invalid syntax"}`,
+		"/users/1/6ba7b810-9dad-11d1-80b4-00c04fd430":    `{"message":"gee: invalid UUID \"6ba7b810-9dad-11d1-80b4-00c04fd430\""}`,
+	}
+	for path, body := range cases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Body.String() != body {
+			t.Fatalf("%s: unexpected body %q", path, w.Body.String())
+		}
+	}
+}
+
+func TestContextClientIP(t *testing.T) {
+	r := New()
+	r.GET("/ip", func(c *Context) {
+		c.String(http.StatusOK, c.ClientIP())
+	})
+	request := func() string {
+		req := httptest.NewRequest("GET", "/ip", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", "1.2.3.4, 10.0.0.2")
+		req.Header.Set("X-Real-IP", "5.6.7.8")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	// 默认不信任客户端可以伪造的头部
+	if ip := request(); ip != "10.0.0.1" {
+		t.Fatalf("X-Forwarded-For should be ignored by default, got %q", ip)
+	}
+	r.ForwardedByClientIP = true
+	if ip := request(); ip != "1.2.3.4" {
+		t.Fatalf("X-Forwarded-For should be used behind trusted proxies, got %q", ip)
+	}
+}
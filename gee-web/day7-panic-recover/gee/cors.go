@@ -0,0 +1,146 @@
+package gee
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig 是CORSWithConfig的配置
+type CORSConfig struct {
+	// 允许的来源，"*"表示任意来源，也可以带一个通配符，例如 https://*.example.com
+	AllowOrigins []string
+	// 自定义的来源判断，AllowOrigins没有匹配时调用
+	AllowOriginFunc func(origin string) bool
+	// 预检请求允许的方法，默认为 GET, POST, PUT, PATCH, DELETE, HEAD, OPTIONS
+	AllowMethods []string
+	// 预检请求允许的头部，为空时原样返回Access-Control-Request-Headers
+	AllowHeaders []string
+	// 浏览器可以读取的响应头
+	ExposeHeaders []string
+	// 是否允许带上cookie等凭据，此时Access-Control-Allow-Origin返回请求的Origin，
+	// 不能和"*"一起使用，只能列出具体的来源或者使用AllowOriginFunc
+	AllowCredentials bool
+	// 预检结果的缓存时间，精确到秒，0表示不设置
+	MaxAge time.Duration
+}
+
+var defaultCORSMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodHead, http.MethodOptions,
+}
+
+// CORS 允许任意来源的跨域请求
+func CORS() HandlerFunc {
+	return CORSWithConfig(CORSConfig{AllowOrigins: []string{"*"}})
+}
+
+/*
+	CORSWithConfig 按配置处理跨域请求
+	预检请求(带Access-Control-Request-Method的OPTIONS请求)直接返回204，不会再执行后面的handler，
+	没有注册OPTIONS路由时，engine和路径所在分组上的中间件也会执行，所以CORS注册在分组上也可以处理预检请求。
+	来源不被允许时，预检请求返回403，普通请求照常处理但不带CORS头部，由浏览器拦截
+*/
+func CORSWithConfig(conf CORSConfig) HandlerFunc {
+	allowAll := false
+	var origins []string
+	for _, origin := range conf.AllowOrigins {
+		if origin == "*" {
+			allowAll = true
+			continue
+		}
+		origins = append(origins, strings.ToLower(origin))
+	}
+	// 任意来源都能带着凭据读取响应，等于没有同源限制
+	if allowAll && conf.AllowCredentials {
+		panic("gee: CORS AllowCredentials can not be used with AllowOrigins \"*\"")
+	}
+	methods := conf.AllowMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	allowMethods := strings.ToUpper(strings.Join(methods, ", "))
+	allowHeaders := strings.Join(conf.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(conf.ExposeHeaders, ", ")
+	maxAge := ""
+	if conf.MaxAge > 0 {
+		maxAge = strconv.FormatInt(int64(conf.MaxAge/time.Second), 10)
+	}
+
+	allowed := func(origin string) bool {
+		if allowAll {
+			return true
+		}
+		lower := strings.ToLower(origin)
+		for _, pattern := range origins {
+			if matchOrigin(pattern, lower) {
+				return true
+			}
+		}
+		return conf.AllowOriginFunc != nil && conf.AllowOriginFunc(origin)
+	}
+
+	return func(c *Context) {
+		origin := c.Req.Header.Get("Origin")
+		if origin == "" {
+			// 不是跨域请求
+			c.Next()
+			return
+		}
+		header := c.Writer.Header()
+		// 响应随Origin变化，缓存不能混用
+		header.Add("Vary", "Origin")
+		preflight := c.Method == http.MethodOptions && c.Req.Header.Get("Access-Control-Request-Method") != ""
+
+		if !allowed(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if allowAll {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if conf.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			header.Set("Access-Control-Allow-Methods", allowMethods)
+			if allowHeaders != "" {
+				header.Set("Access-Control-Allow-Headers", allowHeaders)
+			} else if reqHeaders := c.Req.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" {
+				header.Set("Access-Control-Allow-Headers", reqHeaders)
+			}
+			if maxAge != "" {
+				header.Set("Access-Control-Max-Age", maxAge)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		if exposeHeaders != "" {
+			header.Set("Access-Control-Expose-Headers", exposeHeaders)
+		}
+		c.Next()
+	}
+}
+
+// 模式中最多一个*，例如 https://*.example.com 匹配 https://api.example.com
+func matchOrigin(pattern, origin string) bool {
+	i := strings.IndexByte(pattern, '*')
+	if i < 0 {
+		return pattern == origin
+	}
+	prefix, suffix := pattern[:i], pattern[i+1:]
+	return len(origin) > len(prefix)+len(suffix) &&
+		strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix)
+}
@@ -0,0 +1,125 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newCORSEngine() *Engine {
+	r := New()
+	r.Use(CORSWithConfig(CORSConfig{
+		AllowOrigins:     []string{"https://gee.dev", "https://*.example.com"},
+		AllowOriginFunc:  func(origin string) bool { return origin == "http://localhost:9999" },
+		AllowMethods:     []string{"GET", "POST"},
+		ExposeHeaders:    []string{"X-Total"},
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}))
+	r.POST("/api/users", func(c *Context) {
+		c.String(http.StatusCreated, "created")
+	})
+	return r
+}
+
+func TestCORSPreflight(t *testing.T) {
+	r := newCORSEngine()
+	req := httptest.NewRequest("OPTIONS", "/api/users", nil)
+	req.Header.Set("Origin", "https://api.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	h := w.Header()
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Fatalf("preflight should return 204, got %d %q", w.Code, w.Body.String())
+	}
+	if h.Get("Access-Control-Allow-Origin") != "https://api.example.com" ||
+		h.Get("Access-Control-Allow-Methods") != "GET, POST" ||
+		h.Get("Access-Control-Allow-Headers") != "Content-Type" ||
+		h.Get("Access-Control-Allow-Credentials") != "true" ||
+		h.Get("Access-Control-Max-Age") != "43200" {
+		t.Fatalf("unexpected preflight headers %v", h)
+	}
+	if !strings.Contains(strings.Join(h.Values("Vary"), ","), "Origin") {
+		t.Fatal("Vary should contain Origin")
+	}
+
+	// 没有注册的路径也能处理预检请求
+	req = httptest.NewRequest("OPTIONS", "/api/unknown", nil)
+	req.Header.Set("Origin", "http://localhost:9999")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "http://localhost:9999" {
+		t.Fatalf("unexpected response %d %v", w.Code, w.Header())
+	}
+
+	req = httptest.NewRequest("OPTIONS", "/api/users", nil)
+	req.Header.Set("Origin", "https://evil.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("disallowed origin should get 403, got %d", w.Code)
+	}
+}
+
+func TestCORSSimpleRequest(t *testing.T) {
+	r := newCORSEngine()
+	req := httptest.NewRequest("POST", "/api/users", nil)
+	req.Header.Set("Origin", "https://gee.dev")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated || w.Header().Get("Access-Control-Allow-Origin") != "https://gee.dev" ||
+		w.Header().Get("Access-Control-Expose-Headers") != "X-Total" {
+		t.Fatalf("unexpected response %d %v", w.Code, w.Header())
+	}
+
+	req = httptest.NewRequest("POST", "/api/users", nil)
+	req.Header.Set("Origin", "https://example.com")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("https://example.com should not match https://*.example.com")
+	}
+
+	r = New()
+	r.Use(CORS())
+	r.GET("/", func(c *Context) { c.String(http.StatusOK, "ok") })
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Origin", "https://any.org")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatalf("CORS() should allow any origin, got %v", w.Header())
+	}
+}
+
+func TestCORSOnGroup(t *testing.T) {
+	r := New()
+	api := r.Group("/api")
+	api.Use(CORS())
+	api.GET("/users", func(c *Context) {})
+
+	req := httptest.NewRequest("OPTIONS", "/api/unknown", nil)
+	req.Header.Set("Origin", "https://gee.dev")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatalf("group CORS should handle preflight, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestCORSWildcardWithCredentials(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("\"*\" with AllowCredentials should panic")
+		}
+	}()
+	CORSWithConfig(CORSConfig{AllowOrigins: []string{"*"}, AllowCredentials: true})
+}
@@ -3,8 +3,10 @@ package gee
 import (
 	"html/template"
 	"net/http"
-	"path"
+	"os"
 	"strings"
+	"sync"
+	"time"
 )
 
 // HandlerFunc定义一个handler处理请求路由
@@ -15,10 +17,18 @@ type HandlerFunc func(c *Context)
 // 我们可以在Group中，保存一个指针，指向Engine，
 // 整个框架的所有资源都是由Engine统一协调的，那么就可以通过Engine间接地访问各种接口了。
 type RouterGroup struct {
-	prefix      string        // 用前缀区分分组
-	middlewares []HandlerFunc // 用来处理该前缀对应的分组的方法集合
-	parent      *RouterGroup  // 要支持分组嵌套 需要知道当前分组的父亲(parent)是谁
-	engine      *Engine       // 方便访问router,所有的group共享一个Engine单例
+	prefix      string         // 用前缀区分分组
+	middlewares []HandlerFunc  // 用来处理该前缀对应的分组的方法集合
+	parent      *RouterGroup   // 要支持分组嵌套 需要知道当前分组的父亲(parent)是谁
+	engine      *Engine        // 方便访问router,所有的group共享一个Engine单例
+	host        *hostRouter    // Host分组及其子分组的路由注册到host.router，为nil时注册到engine.router
+	segments    []groupSegment // 解析后的前缀，没有匹配到路由时用来查找路径所在的分组
+}
+
+// groupSegment 是分组前缀中的一段，:参数的约束在创建分组时解析好
+type groupSegment struct {
+	text       string
+	constraint *paramConstraint
 }
 
 // Engine implement the interface of ServeHTTP
@@ -28,9 +38,50 @@ type Engine struct {
 	*RouterGroup
 	router *router
 	groups []*RouterGroup // 保存全部的group
+	routes []*routeEntry  // 保存全部的路由
+	pool   sync.Pool      // 复用Context，避免每个请求都分配
+
+	// 路径存在但请求方法没有注册时，检查其它方法的Trie树，返回405并带上Allow头，否则返回404。默认关闭
+	HandleMethodNotAllowed bool
+	// 没有注册OPTIONS路由时，自动回复OPTIONS请求，Allow头中列出该路径允许的方法。默认关闭
+	HandleOPTIONS bool
+	// 没有注册HEAD路由时，使用同一路径的GET handler处理HEAD请求。默认关闭
+	HandleHEAD bool
+	// 路径只差结尾的/时重定向，例如注册了/hello，请求/hello/时重定向到/hello
+	RedirectTrailingSlash bool
+	// 没有匹配到路由时，去掉多余的/和..，再不区分大小写地查找，找到时重定向，例如 /HELLO//world 重定向到 /hello/world
+	RedirectFixedPath bool
+	// 用转义前的url.RawPath匹配路由，参数中的%2F不会被当成/
+	UseRawPath bool
+	// UseRawPath时，把匹配到的参数值反转义
+	UnescapePathValues bool
+	// Context.SecureJSON 在JSON数组前加的前缀
+	SecureJSONPrefix string
+	// Context.ClientIP 是否从X-Forwarded-For、X-Real-IP中获取客户端IP，默认关闭，只在可信的代理后面打开
+	ForwardedByClientIP bool
+	// 解析multipart表单时最多使用的内存，超出的部分写到临时文件
+	MaxMultipartMemory int64
+
+	// http.Server的配置，Run系列方法启动服务时使用，为0时表示不限制
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+
+	serversMu sync.Mutex
+	servers   []*http.Server // Run系列方法启动的服务，Shutdown时逐个关闭
+	shutdown  bool
 
-	htmlTemplates *template.Template // 将所有的模板加载进内存
-	funcMap       template.FuncMap   // 自定义模板渲染函数
+	namedRoutes map[string]*Route // Route.Name注册的路由，Engine.URL按名字查找
+	noRoute     []HandlerFunc     // 没有匹配到路由时执行，默认返回404
+	noMethod    []HandlerFunc     // 路径存在但方法不允许时执行，默认返回405
+	hosts       []*hostRouter     // Host注册的路由，按顺序匹配
+
+	// Context.HTML使用的模板引擎，LoadHTML系列方法会设置它
+	HTMLRender HTMLRender
+	htmlLoader *htmlLoader      // 最近一次加载模板的方式，SetFuncMap之后用它重新加载
+	funcMap    template.FuncMap // 自定义模板渲染函数
 }
 
 // gee.go
@@ -43,12 +94,35 @@ func Default() *Engine {
 
 // gee.Engine的构造函数
 func New() *Engine {
-	engine := &Engine{router: newRouter()}
+	engine := &Engine{
+		router:                newRouter(),
+		RedirectTrailingSlash: true,
+		UnescapePathValues:    true,
+		SecureJSONPrefix:      "while(1);",
+		MaxMultipartMemory:    defaultMultipartMemory,
+	}
 	engine.RouterGroup = &RouterGroup{engine: engine}
+	engine.noRoute = []HandlerFunc{defaultNoRoute}
+	engine.noMethod = []HandlerFunc{defaultNoMethod}
 	engine.groups = []*RouterGroup{engine.RouterGroup}
+	engine.pool.New = func() interface{} {
+		return engine.allocateContext()
+	}
 	return engine
 }
 
+// 新建一个Context，Params按最多参数的路由预留容量
+func (engine *Engine) allocateContext() *Context {
+	maxParams := engine.router.maxParams
+	for _, h := range engine.hosts {
+		if n := h.router.maxParams + h.nParams; n > maxParams {
+			maxParams = n
+		}
+	}
+	params := make(Params, 0, maxParams)
+	return &Context{engine: engine, params: &params}
+}
+
 // 创建新的路由分组
 // 所有的group共享一个Engine单例
 func (group *RouterGroup) Group(prefix string) *RouterGroup {
@@ -57,6 +131,14 @@ func (group *RouterGroup) Group(prefix string) *RouterGroup {
 		prefix: group.prefix + prefix,
 		parent: group,
 		engine: engine,
+		host:   group.host,
+	}
+	for _, part := range parsePattern(newGroup.prefix) {
+		seg := groupSegment{text: part}
+		if part[0] == ':' {
+			_, seg.constraint = parseParam(part, newGroup.prefix)
+		}
+		newGroup.segments = append(newGroup.segments, seg)
 	}
 	engine.groups = append(engine.groups, newGroup)
 	return newGroup
@@ -64,84 +146,192 @@ func (group *RouterGroup) Group(prefix string) *RouterGroup {
 }
 
 // 给engine增加路由的handler
-func (group *RouterGroup) addRoute(method string, prefix string, handler HandlerFunc) {
+func (group *RouterGroup) addRoute(method string, prefix string, handler HandlerFunc) *Route {
 	engine := group.engine
 	pattern := group.prefix + prefix // /v1 + /hello
-	engine.router.addRoute(method, pattern, handler)
+	router := engine.router
+	if group.host != nil {
+		router = group.host.router
+	}
+	router.addRoute(method, pattern, group.combineHandlers(handler))
+	engine.routes = append(engine.routes, &routeEntry{router, method, pattern, group, handler})
+	return &Route{Method: method, Pattern: pattern, engine: engine}
+}
+
+// 已经注册的路由，分组调用Use之后用来重新拼接调用链
+type routeEntry struct {
+	router  *router
+	method  string
+	pattern string
+	group   *RouterGroup
+	handler HandlerFunc
+}
+
+// 注册路由时就把从根分组到当前分组的中间件和handler拼成完整的调用链，
+// 处理请求时不用再遍历所有分组的前缀
+func (group *RouterGroup) combineHandlers(handlers ...HandlerFunc) []HandlerFunc {
+	size := len(handlers)
+	for g := group; g != nil; g = g.parent {
+		size += len(g.middlewares)
+	}
+	merged := make([]HandlerFunc, size)
+	i := size - len(handlers)
+	copy(merged[i:], handlers)
+	for g := group; g != nil; g = g.parent {
+		i -= len(g.middlewares)
+		copy(merged[i:], g.middlewares)
+	}
+	return merged
+}
+
+// anyMethods 是Any注册的全部请求方法
+var anyMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodHead, http.MethodOptions,
+	http.MethodConnect, http.MethodTrace,
+}
+
+// Handle 用任意请求方法注册路由，GET、POST等都是它的简写
+func (group *RouterGroup) Handle(method string, pattern string, handler HandlerFunc) *Route {
+	return group.addRoute(method, pattern, handler)
 }
 
 // GET defines the method to add GET request
 // 调用GET可以给engin绑定一个请求为GET的路由，可以有多个这样的路由
 // 实际上就是 "GET-/"或者"GET-hello"作为key
-func (group *RouterGroup) GET(pattern string, handler HandlerFunc) {
-	group.addRoute("GET", pattern, handler)
+func (group *RouterGroup) GET(pattern string, handler HandlerFunc) *Route {
+	return group.addRoute(http.MethodGet, pattern, handler)
 }
 
 // POST defines the method to add POST request
-func (group *RouterGroup) POST(pattern string, handler HandlerFunc) {
-	group.addRoute("POST", pattern, handler)
+func (group *RouterGroup) POST(pattern string, handler HandlerFunc) *Route {
+	return group.addRoute(http.MethodPost, pattern, handler)
 }
 
-// Run defines the method to start a http server
-func (engine *Engine) Run(addr string) (err error) {
-	return http.ListenAndServe(addr, engine)
+// PUT defines the method to add PUT request
+func (group *RouterGroup) PUT(pattern string, handler HandlerFunc) *Route {
+	return group.addRoute(http.MethodPut, pattern, handler)
 }
 
-// 修改了ServeHTTP的逻辑，将具体逻辑封装到handle函数，
-func (engine *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	var middlewares []HandlerFunc
-	for _, group := range engine.groups {
-		if strings.HasPrefix(req.URL.Path, group.prefix) {
-			middlewares = append(middlewares, group.middlewares...)
+// PATCH defines the method to add PATCH request
+func (group *RouterGroup) PATCH(pattern string, handler HandlerFunc) *Route {
+	return group.addRoute(http.MethodPatch, pattern, handler)
+}
+
+// DELETE defines the method to add DELETE request
+func (group *RouterGroup) DELETE(pattern string, handler HandlerFunc) *Route {
+	return group.addRoute(http.MethodDelete, pattern, handler)
+}
+
+// HEAD defines the method to add HEAD request
+func (group *RouterGroup) HEAD(pattern string, handler HandlerFunc) *Route {
+	return group.addRoute(http.MethodHead, pattern, handler)
+}
+
+// OPTIONS defines the method to add OPTIONS request
+func (group *RouterGroup) OPTIONS(pattern string, handler HandlerFunc) *Route {
+	return group.addRoute(http.MethodOptions, pattern, handler)
+}
+
+// Any 给同一个pattern注册所有请求方法
+func (group *RouterGroup) Any(pattern string, handler HandlerFunc) *Route {
+	for _, method := range anyMethods {
+		group.addRoute(method, pattern, handler)
+	}
+	return &Route{Method: "ANY", Pattern: group.prefix + pattern, engine: group.engine}
+}
+
+/*
+	NoRoute 设置没有匹配到路由时的handler，例如返回统一格式的JSON错误
+	执行前状态码已经设为404，之前会先执行engine和路径所在分组的中间件，所以Logger、Recovery、CORS对404同样有效
+*/
+func (engine *Engine) NoRoute(handlers ...HandlerFunc) {
+	engine.noRoute = handlers
+}
+
+// NoMethod 设置路径存在但请求方法不允许时的handler，执行前已经设置了405和Allow头，需要打开HandleMethodNotAllowed
+func (engine *Engine) NoMethod(handlers ...HandlerFunc) {
+	engine.noMethod = handlers
+}
+
+func defaultNoRoute(c *Context) {
+	c.String(http.StatusNotFound, "404 NOT FOUND: %s\n", c.Path)
+}
+
+func defaultNoMethod(c *Context) {
+	c.String(http.StatusMethodNotAllowed, "405 METHOD NOT ALLOWED: %s\n", c.Path)
+}
+
+/*
+	missHandlers 没有匹配到路由时的调用链
+	找到前缀和路径按段匹配的最深的分组，例如 /v1/unknown 属于 /v1 分组而不属于 /v10，
+	执行从engine到这个分组的中间件，再执行handlers。Host的路由只在这个Host的分组中查找
+*/
+func (engine *Engine) missHandlers(r *router, path string, handlers ...HandlerFunc) []HandlerFunc {
+	group := engine.RouterGroup
+	if r.host != nil {
+		group = r.host.group
+	}
+	depth := len(group.prefix)
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for _, g := range engine.groups {
+		if d := len(g.prefix); g.host == r.host && d > depth && g.matchPrefix(parts) {
+			group, depth = g, d
+		}
+	}
+	return group.combineHandlers(handlers...)
+}
+
+// 分组前缀中也可以有:参数和*通配，parts是按/切分后的请求路径
+func (group *RouterGroup) matchPrefix(parts []string) bool {
+	for i, seg := range group.segments {
+		if seg.text[0] == '*' {
+			return true
+		}
+		if i >= len(parts) || parts[i] == "" {
+			return false
+		}
+		if seg.text[0] != ':' {
+			if seg.text != parts[i] {
+				return false
+			}
+		} else if seg.constraint != nil && !seg.constraint.match(parts[i]) {
+			return false
 		}
 	}
-	c := newContext(w, req)
-	c.handlers = middlewares
-	c.engine = engine // add
-	engine.router.handle(c)
+	return true
+}
+
+// 修改了ServeHTTP的逻辑，将具体逻辑封装到handle函数，
+// Context从池中取出，请求处理完之后重置并放回
+func (engine *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	c := engine.pool.Get().(*Context)
+	c.reset(w, req)
+	engine.matchHost(c).handle(c)
+	// 只设置了状态码、没有写入响应体的请求，在这里发送响应头
+	c.Writer.WriteHeaderNow()
+	engine.pool.Put(c)
 }
 
 // 将给group增加传入的handler
+// 调用链在注册路由时就拼好了，之前已经注册的路由在这里重新拼接，所以先注册路由再Use同样生效
 func (group *RouterGroup) Use(middlewares ...HandlerFunc) {
 	group.middlewares = append(group.middlewares, middlewares...)
-}
-
-// 根据传入的相对路径（相对localhost的路径）先构造完整的绝对路径
-// 如 group是/v1,相对路径是/assert，则拼接起来是 /vi/assert
-// fs是映射的文件系统
-func (group *RouterGroup) createStaticHandler(relativePath string, fs http.FileSystem) HandlerFunc {
-	absolutePath := path.Join(group.prefix, relativePath)
-	fileServer := http.StripPrefix(absolutePath, http.FileServer(fs))
-	return func(c *Context) {
-		file := c.Param("filepath")
-		// Check if file exists and/or if we have permission to access it
-		if _, err := fs.Open(file); err != nil {
-			c.Status(http.StatusNotFound)
-			return
+	for _, route := range group.engine.routes {
+		for g := route.group; g != nil; g = g.parent {
+			if g == group {
+				route.router.setHandlers(route.method, route.pattern, route.group.combineHandlers(route.handler))
+				break
+			}
 		}
-
-		fileServer.ServeHTTP(c.Writer, c.Req)
 	}
 }
 
-// 解析请求的地址，映射到服务器上文件的真实地址，交给http.FileServer处理就好了。
 // 将磁盘上的某个文件夹root映射到路由relativePath。
 // eg:
 // r.Static("/assets", "/usr/geektutu/blog/static")
 // 或相对路径 r.Static("/assets", "./static")
+// 和以前直接使用http.FileServer不同，不再列出目录，响应会带上ETag，需要列出目录等选项时使用StaticFS
 func (group *RouterGroup) Static(relativePath string, root string) {
-	// 将相对路径和文件系统交给createStaticHandler，构造一个handler
-	handler := group.createStaticHandler(relativePath, http.Dir(root))
-	urlPattern := path.Join(relativePath, "/*filepath")
-	// Register GET handlers
-	group.GET(urlPattern, handler)
-
-}
-
-func (engine *Engine) SetFuncMap(funcMap template.FuncMap) {
-	engine.funcMap = funcMap
-}
-
-func (engine *Engine) LoadHTMLGlob(pattern string) {
-	engine.htmlTemplates = template.Must(template.New("").Funcs(engine.funcMap).ParseGlob(pattern))
+	group.StaticFS(relativePath, os.DirFS(root), StaticConfig{})
 }
@@ -0,0 +1,14 @@
+package gee
+
+import "net/http/httptest"
+
+// 测试中发送一个请求，headers是成对的头部名和值
+func performRequest(r *Engine, method, path string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
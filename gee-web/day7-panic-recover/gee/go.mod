@@ -1,3 +1,8 @@
 module gee
 
 go 1.16
+
+require (
+	google.golang.org/protobuf v1.27.1
+	gopkg.in/yaml.v3 v3.0.1
+)
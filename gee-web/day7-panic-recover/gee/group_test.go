@@ -1,6 +1,12 @@
 package gee
 
-import "testing"
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
 
 func TestNestedGroup(t *testing.T) {
 	r := New()
@@ -14,3 +20,146 @@ func TestNestedGroup(t *testing.T) {
 		t.Fatal("v2 prefix should be /v1/v2")
 	}
 }
+
+func TestGroupMiddleware(t *testing.T) {
+	r := New()
+	var trace []string
+	mark := func(name string) HandlerFunc {
+		return func(c *Context) {
+			trace = append(trace, name)
+			c.Next()
+		}
+	}
+	r.Use(mark("engine"))
+	v1 := r.Group("/v1")
+	v2 := v1.Group("/v2")
+	v1.Use(mark("v1"))
+	v2.Use(mark("v2"))
+	v2.GET("/hello", func(c *Context) {
+		trace = append(trace, "handler")
+	})
+	r.GET("/v1x", func(c *Context) {
+		trace = append(trace, "handler")
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/v2/hello", nil))
+	if !reflect.DeepEqual(trace, []string{"engine", "v1", "v2", "handler"}) {
+		t.Fatalf("unexpected middleware order %v", trace)
+	}
+
+	// /v1x 不属于 /v1 分组
+	trace = nil
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1x", nil))
+	if !reflect.DeepEqual(trace, []string{"engine", "handler"}) {
+		t.Fatalf("unexpected middleware order %v", trace)
+	}
+
+	// 没有匹配到路由时执行engine和路径所在分组的中间件
+	trace = nil
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/v1/none", nil))
+	if w.Code != 404 || !reflect.DeepEqual(trace, []string{"engine", "v1"}) {
+		t.Fatalf("unexpected 404 handling %d %v", w.Code, trace)
+	}
+	trace = nil
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v10/none", nil))
+	if !reflect.DeepEqual(trace, []string{"engine"}) {
+		t.Fatalf("unexpected 404 handling %v", trace)
+	}
+}
+
+func TestGroupUseAfterRoute(t *testing.T) {
+	r := New()
+	var trace []string
+	mark := func(name string) HandlerFunc {
+		return func(c *Context) {
+			trace = append(trace, name)
+			c.Next()
+		}
+	}
+	v1 := r.Group("/v1")
+	v1.GET("/hello", func(c *Context) {
+		trace = append(trace, "handler")
+	})
+	r.GET("/hello", func(c *Context) {
+		trace = append(trace, "handler")
+	})
+	// 先注册路由，再添加中间件
+	r.Use(mark("engine"))
+	v1.Use(mark("v1"))
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/hello", nil))
+	if !reflect.DeepEqual(trace, []string{"engine", "v1", "handler"}) {
+		t.Fatalf("unexpected middleware order %v", trace)
+	}
+	trace = nil
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/hello", nil))
+	if !reflect.DeepEqual(trace, []string{"engine", "handler"}) {
+		t.Fatalf("unexpected middleware order %v", trace)
+	}
+}
+
+func TestNoRouteAndNoMethod(t *testing.T) {
+	r := New()
+	r.HandleMethodNotAllowed = true
+	var trace []string
+	r.Use(func(c *Context) {
+		trace = append(trace, "engine")
+		c.Next()
+	})
+	api := r.Group("/api/:version")
+	api.Use(func(c *Context) {
+		trace = append(trace, "api")
+		c.Next()
+	})
+	api.GET("/users", func(c *Context) {})
+	r.StaticFS("/assets", fstest.MapFS{"gee.css": {Data: []byte("body{}")}}, StaticConfig{})
+	r.NoRoute(func(c *Context) {
+		c.JSON(c.Writer.Status(), H{"code": "not_found", "path": c.Path})
+	})
+	r.NoMethod(func(c *Context) {
+		c.JSON(c.Writer.Status(), H{"code": "method_not_allowed"})
+	})
+
+	cases := []struct {
+		method, path string
+		code         int
+		body         string
+		trace        []string
+	}{
+		{"GET", "/none", http.StatusNotFound, `{"code":"not_found","path":"/none"}`, []string{"engine"}},
+		{"GET", "/api/v1/none", http.StatusNotFound, `{"code":"not_found","path":"/api/v1/none"}`, []string{"engine", "api"}},
+		{"POST", "/api/v1/users", http.StatusMethodNotAllowed, `{"code":"method_not_allowed"}`, []string{"engine", "api"}},
+		// 静态文件不存在时同样执行NoRoute
+		{"GET", "/assets/none.css", http.StatusNotFound, `{"code":"not_found","path":"/assets/none.css"}`, []string{"engine"}},
+	}
+	for _, tc := range cases {
+		trace = nil
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		if w.Code != tc.code || w.Body.String() != tc.body || !reflect.DeepEqual(trace, tc.trace) {
+			t.Fatalf("%s %s: unexpected response %d %q %v", tc.method, tc.path, w.Code, w.Body.String(), trace)
+		}
+	}
+
+	// 只记录日志的NoRoute也返回404
+	r.NoRoute(func(c *Context) {})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/none", nil))
+	if w.Code != http.StatusNotFound || w.Body.Len() != 0 {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+}
+
+func BenchmarkEngineServeHTTP(b *testing.B) {
+	r := New()
+	r.Use(func(c *Context) { c.Next() })
+	r.GET("/user/:name/repos/:repo", func(c *Context) {})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/user/geektutu/repos/gee", nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.ServeHTTP(w, req)
+	}
+}
@@ -0,0 +1,280 @@
+package gee
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+)
+
+// GzipConfig 是GzipWithConfig的配置
+type GzipConfig struct {
+	// 压缩级别，gzip.BestSpeed到gzip.BestCompression，0表示gzip.DefaultCompression
+	Level int
+	// 响应体小于这个长度时不压缩，默认1024
+	MinLength int
+	// 以这些前缀开头的路径不压缩，例如 /metrics
+	ExcludedPaths []string
+	// 这些扩展名的路径不压缩，例如 .png
+	ExcludedExtensions []string
+}
+
+// Gzip 使用默认配置压缩响应
+func Gzip() HandlerFunc {
+	return GzipWithConfig(GzipConfig{})
+}
+
+/*
+	GzipWithConfig 按Accept-Encoding选择gzip或者deflate压缩响应
+	响应体先缓存到MinLength，根据长度和Content-Type决定是否压缩，之后才发送响应头，
+	已经设置了Content-Encoding或者本身已经压缩过的类型(图片、视频、压缩包)原样输出。
+	压缩器用sync.Pool复用
+*/
+func GzipWithConfig(conf GzipConfig) HandlerFunc {
+	if conf.Level == 0 {
+		conf.Level = gzip.DefaultCompression
+	}
+	if conf.MinLength <= 0 {
+		conf.MinLength = 1024
+	}
+	if _, err := gzip.NewWriterLevel(ioutil.Discard, conf.Level); err != nil {
+		panic(err)
+	}
+	exts := make(map[string]bool, len(conf.ExcludedExtensions))
+	for _, ext := range conf.ExcludedExtensions {
+		exts[strings.ToLower(ext)] = true
+	}
+	pools := map[string]*sync.Pool{
+		"gzip": {New: func() interface{} {
+			w, _ := gzip.NewWriterLevel(ioutil.Discard, conf.Level)
+			return w
+		}},
+		"deflate": {New: func() interface{} {
+			w, _ := flate.NewWriter(ioutil.Discard, conf.Level)
+			return w
+		}},
+	}
+
+	return func(c *Context) {
+		if exts[strings.ToLower(path.Ext(c.Path))] {
+			c.Next()
+			return
+		}
+		for _, prefix := range conf.ExcludedPaths {
+			if strings.HasPrefix(c.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+		// websocket等升级协议的请求不能压缩
+		if c.Req.Header.Get("Upgrade") != "" {
+			c.Next()
+			return
+		}
+		header := c.Writer.Header()
+		if !headerContains(header, "Vary", "Accept-Encoding") {
+			header.Add("Vary", "Accept-Encoding")
+		}
+		encoding := negotiateEncoding(c.Req.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{
+			ResponseWriter: c.Writer,
+			encoding:       encoding,
+			pool:           pools[encoding],
+			minLength:      conf.MinLength,
+			size:           noWritten,
+		}
+		c.Writer = w
+		defer func() {
+			c.Writer = w.ResponseWriter
+			if p := recover(); p != nil {
+				// 丢掉还没发送的内容，外层的Recovery还可以返回500
+				w.buf = nil
+				w.size = noWritten
+				w.close()
+				panic(p)
+			}
+			w.finish()
+		}()
+		c.Next()
+	}
+}
+
+// 按q值选择gzip或者deflate，都不接受时返回空字符串
+func negotiateEncoding(header string) string {
+	for _, encoding := range parseAccept(strings.ToLower(header)) {
+		switch encoding {
+		case "gzip", "x-gzip", "*":
+			return "gzip"
+		case "deflate":
+			return "deflate"
+		}
+	}
+	return ""
+}
+
+func headerContains(header http.Header, key, value string) bool {
+	for _, v := range header.Values(key) {
+		for _, item := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 这些类型本身已经压缩过，再压缩只会浪费CPU
+func isCompressedType(contentType string) bool {
+	mime := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch {
+	case mime == "image/svg+xml":
+		return false
+	case strings.HasPrefix(mime, "image/"), strings.HasPrefix(mime, "video/"), strings.HasPrefix(mime, "audio/"):
+		return true
+	}
+	switch mime {
+	case "application/zip", "application/gzip", "application/x-gzip", "application/x-bzip2",
+		"application/x-xz", "application/zstd", "application/x-7z-compressed", "application/x-rar-compressed",
+		"font/woff", "font/woff2":
+		return true
+	}
+	return false
+}
+
+// compressWriter 缓存响应体直到可以决定是否压缩
+type compressWriter struct {
+	ResponseWriter
+	encoding  string
+	pool      *sync.Pool
+	minLength int
+
+	buf      []byte
+	size     int // 写入的未压缩字节数
+	decided  bool
+	compress bool
+	cw       io.WriteCloser
+}
+
+type resetWriteCloser interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// 决定是否压缩，并把缓存的内容写出去，want为false时原样输出
+func (w *compressWriter) decide(want bool) error {
+	if w.decided {
+		return nil
+	}
+	w.decided = true
+	header := w.Header()
+	if header.Get("Content-Type") == "" && len(w.buf) > 0 {
+		// 压缩之后net/http就不能再根据内容推断类型了
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	w.compress = want && header.Get("Content-Encoding") == "" && header.Get("Content-Range") == "" &&
+		bodyAllowedForStatus(w.ResponseWriter.Status()) && !isCompressedType(header.Get("Content-Type"))
+
+	buf := w.buf
+	w.buf = nil
+	if !w.compress {
+		if len(buf) == 0 {
+			return nil
+		}
+		_, err := w.ResponseWriter.Write(buf)
+		return err
+	}
+	header.Set("Content-Encoding", w.encoding)
+	header.Del("Content-Length")
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		// 压缩后的内容和原来的字节不同，强ETag要变成弱ETag
+		header.Set("ETag", "W/"+etag)
+	}
+	cw := w.pool.Get().(resetWriteCloser)
+	cw.Reset(w.ResponseWriter)
+	w.cw = cw
+	_, err := cw.Write(buf)
+	return err
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.Written() {
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.size == noWritten {
+		w.size = 0
+	}
+	w.size += len(data)
+	if !w.decided {
+		w.buf = append(w.buf, data...)
+		if len(w.buf) < w.minLength {
+			return len(data), nil
+		}
+		return len(data), w.decide(true)
+	}
+	if w.compress {
+		return w.cw.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Written 内容还在缓存中时也算已经写入，不能再修改状态码
+func (w *compressWriter) Written() bool {
+	return w.size != noWritten || w.ResponseWriter.Written()
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	w.decide(len(w.buf) >= w.minLength)
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+// Flush 流式响应不再等待MinLength，已经缓存了内容就开始压缩
+func (w *compressWriter) Flush() {
+	w.decide(len(w.buf) > 0)
+	if w.compress {
+		w.cw.(resetWriteCloser).Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.decided = true
+	return w.ResponseWriter.Hijack()
+}
+
+// 请求处理完，写出剩下的内容
+func (w *compressWriter) finish() {
+	if len(w.buf) > 0 {
+		w.decide(false)
+	}
+	w.close()
+}
+
+func (w *compressWriter) close() {
+	if w.cw == nil {
+		return
+	}
+	w.cw.Close()
+	w.cw.(resetWriteCloser).Reset(ioutil.Discard)
+	w.pool.Put(w.cw)
+	w.cw = nil
+}
@@ -0,0 +1,103 @@
+package gee
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newGzipEngine() *Engine {
+	r := New()
+	r.Use(GzipWithConfig(GzipConfig{MinLength: 100, ExcludedPaths: []string{"/metrics"}, ExcludedExtensions: []string{".txt"}}))
+	long := strings.Repeat("gee ", 100)
+	r.GET("/long", func(c *Context) { c.JSON(http.StatusOK, H{"data": long}) })
+	r.GET("/short", func(c *Context) { c.String(http.StatusOK, "gee") })
+	r.GET("/metrics", func(c *Context) { c.String(http.StatusOK, long) })
+	r.GET("/robots.txt", func(c *Context) { c.String(http.StatusOK, long) })
+	r.GET("/image", func(c *Context) {
+		c.SetHeader("Content-Type", "image/png")
+		c.Data(http.StatusOK, []byte(long))
+	})
+	r.GET("/stream", func(c *Context) {
+		c.Writer.WriteString("data: gee\n\n")
+		c.Writer.Flush()
+		c.Writer.WriteString("data: web\n\n")
+	})
+	return r
+}
+
+func TestGzip(t *testing.T) {
+	r := newGzipEngine()
+	w := performRequest(r, "GET", "/long", "Accept-Encoding", "gzip, deflate")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("unexpected response %d %v", w.Code, w.Header())
+	}
+	if w.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Fatalf("Content-Type should be kept, got %q", w.Header().Get("Content-Type"))
+	}
+	gr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(gr)
+	if !strings.HasPrefix(string(body), `{"data":"gee gee`) {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	w = performRequest(r, "GET", "/long", "Accept-Encoding", "gzip;q=0, deflate")
+	if w.Header().Get("Content-Encoding") != "deflate" {
+		t.Fatalf("should negotiate deflate, got %v", w.Header())
+	}
+	body, _ = ioutil.ReadAll(flate.NewReader(w.Body))
+	if !strings.HasPrefix(string(body), `{"data":"gee gee`) {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	// 流式响应Flush之后就开始压缩
+	w = performRequest(r, "GET", "/stream", "Accept-Encoding", "gzip")
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("stream should be compressed, got %v", w.Header())
+	}
+	gr, _ = gzip.NewReader(w.Body)
+	body, _ = ioutil.ReadAll(gr)
+	if string(body) != "data: gee\n\ndata: web\n\n" {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
+
+func TestGzipSkipped(t *testing.T) {
+	r := newGzipEngine()
+	cases := []struct {
+		path, acceptEncoding string
+	}{
+		{"/long", ""},
+		{"/long", "br"},
+		{"/short", "gzip"},
+		{"/metrics", "gzip"},
+		{"/robots.txt", "gzip"},
+		{"/image", "gzip"},
+	}
+	for _, tc := range cases {
+		w := performRequest(r, "GET", tc.path, "Accept-Encoding", tc.acceptEncoding)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "" {
+			t.Fatalf("%s %q should not be compressed, got %d %v", tc.path, tc.acceptEncoding, w.Code, w.Header())
+		}
+	}
+	if w := performRequest(r, "GET", "/short", "Accept-Encoding", "gzip"); w.Body.String() != "gee" {
+		t.Fatalf("unexpected body %q", w.Body.String())
+	}
+}
+
+func BenchmarkGzip(b *testing.B) {
+	r := newGzipEngine()
+	req := httptest.NewRequest("GET", "/long", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
@@ -0,0 +1,131 @@
+package gee
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+	按请求的Host选择路由，每个Host有自己独立的Trie树，例如
+	api := r.Host("api.example.com")
+	api.GET("/users", ...)
+	tenant := r.Host(":tenant.example.com")
+	tenant.GET("/", func(c *gee.Context) { c.Param("tenant") })
+	Host中以:开头的一段匹配任意一个标签，也可以像路由参数一样带约束，例如 :id<int>.example.com
+	匹配时忽略大小写和端口，固定的Host优先于带参数的Host，同类的按注册顺序匹配
+	匹配到某个Host之后只在它的路由中查找，找不到时返回404，不会再回到默认的路由
+*/
+type hostRouter struct {
+	pattern string
+	labels  []hostLabel
+	nParams int          // Host中参数的个数
+	router  *router      // 这个Host注册的路由
+	group   *RouterGroup // Host对应的根分组
+}
+
+// Host按.分成标签，key不为空的是参数
+type hostLabel struct {
+	text       string
+	key        string
+	constraint *paramConstraint
+}
+
+// Host 返回一个按Host匹配的分组，可以继续Group、Use，中间件从engine开始继承
+// 同一个pattern多次调用返回同一个分组
+func (engine *Engine) Host(pattern string) *RouterGroup {
+	for _, h := range engine.hosts {
+		if h.pattern == pattern {
+			return h.group
+		}
+	}
+	h := &hostRouter{pattern: pattern, router: newRouter()}
+	h.group = &RouterGroup{parent: engine.RouterGroup, engine: engine, host: h}
+	h.router.host = h
+	for _, text := range strings.Split(pattern, ".") {
+		if text == "" {
+			panic(fmt.Sprintf("gee: invalid host '%s'", pattern))
+		}
+		label := hostLabel{text: text}
+		if text[0] == ':' {
+			label.key, label.constraint = parseParam(text, pattern)
+			if label.key == "" {
+				panic(fmt.Sprintf("gee: wildcard in host '%s' must be named", pattern))
+			}
+			h.nParams++
+		} else if strings.ContainsAny(text, ":*") {
+			panic(fmt.Sprintf("gee: invalid host '%s'", pattern))
+		}
+		h.labels = append(h.labels, label)
+	}
+
+	// 固定的Host放在带参数的Host前面
+	i := len(engine.hosts)
+	if h.nParams == 0 {
+		for i > 0 && engine.hosts[i-1].nParams > 0 {
+			i--
+		}
+	}
+	engine.hosts = append(engine.hosts, nil)
+	copy(engine.hosts[i+1:], engine.hosts[i:])
+	engine.hosts[i] = h
+	engine.groups = append(engine.groups, h.group)
+	return h.group
+}
+
+// 选择请求的Host对应的路由，Host中的参数追加到c.params中，没有匹配的Host时使用默认的路由
+func (engine *Engine) matchHost(c *Context) *router {
+	if len(engine.hosts) == 0 {
+		return engine.router
+	}
+	host := stripHostPort(c.Req.Host)
+	for _, h := range engine.hosts {
+		if h.match(host, c.params) {
+			return h.router
+		}
+	}
+	return engine.router
+}
+
+// 逐个标签比较host，不切分字符串，失败时恢复params
+func (h *hostRouter) match(host string, params *Params) bool {
+	mark := len(*params)
+	for i, label := range h.labels {
+		end := strings.IndexByte(host, '.')
+		if end < 0 {
+			end = len(host)
+		}
+		value := host[:end]
+		if i < len(h.labels)-1 {
+			if end == len(host) {
+				*params = (*params)[:mark]
+				return false
+			}
+			host = host[end+1:]
+		} else if end != len(host) {
+			*params = (*params)[:mark]
+			return false
+		}
+
+		if label.key == "" {
+			if !strings.EqualFold(label.text, value) {
+				*params = (*params)[:mark]
+				return false
+			}
+			continue
+		}
+		if value == "" || (label.constraint != nil && !label.constraint.match(value)) {
+			*params = (*params)[:mark]
+			return false
+		}
+		*params = append(*params, Param{Key: label.key, Value: value})
+	}
+	return true
+}
+
+// 去掉Host中的端口和结尾的.，IPv6地址保留[]
+func stripHostPort(host string) string {
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && strings.IndexByte(host[i:], ']') < 0 {
+		host = host[:i]
+	}
+	return strings.TrimSuffix(host, ".")
+}
@@ -0,0 +1,127 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHostRouting(t *testing.T) {
+	r := New()
+	r.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "default")
+	})
+	tenant := r.Host(":tenant.example.com")
+	tenant.GET("/users/:id", func(c *Context) {
+		c.String(http.StatusOK, "%s %s", c.Param("tenant"), c.Param("id"))
+	})
+	r.Host("api.example.com").GET("/users/:id", func(c *Context) {
+		c.String(http.StatusOK, "api %s", c.Param("id"))
+	})
+	r.Host(":id<int>.shard.example.com").GET("/", func(c *Context) {
+		c.String(http.StatusOK, "shard %s", c.Param("id"))
+	})
+	if r.Host(":tenant.example.com") != tenant {
+		t.Fatal("same pattern should return the same group")
+	}
+
+	cases := []struct {
+		host, path string
+		code       int
+		body       string
+	}{
+		{"acme.example.com", "/users/1", http.StatusOK, "acme 1"},
+		{"ACME.Example.com:8080", "/users/2", http.StatusOK, "ACME 2"},
+		{"acme.example.com.", "/users/3", http.StatusOK, "acme 3"},
+		// 固定的Host优先于带参数的Host，即使注册在后面
+		{"api.example.com", "/users/1", http.StatusOK, "api 1"},
+		{"7.shard.example.com", "/", http.StatusOK, "shard 7"},
+		{"x.shard.example.com", "/", http.StatusOK, "default"},
+		{"example.com", "/", http.StatusOK, "default"},
+		{"a.b.example.com", "/", http.StatusOK, "default"},
+		// 匹配到Host之后不会回到默认的路由
+		{"acme.example.com", "/", http.StatusNotFound, "404 NOT FOUND: /\n"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("GET", tc.path, nil)
+		req.Host = tc.host
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.code || w.Body.String() != tc.body {
+			t.Fatalf("%s%s: unexpected response %d %q", tc.host, tc.path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestHostGroupMiddleware(t *testing.T) {
+	r := New()
+	r.HandleMethodNotAllowed = true
+	var trace []string
+	mark := func(name string) HandlerFunc {
+		return func(c *Context) {
+			trace = append(trace, name)
+			c.Next()
+		}
+	}
+	r.Use(mark("engine"))
+	tenant := r.Host(":tenant.example.com")
+	tenant.Use(mark("tenant"))
+	v1 := tenant.Group("/v1")
+	v1.Use(mark("v1"))
+	v1.GET("/hello", func(c *Context) {
+		c.String(http.StatusOK, "hello %s", c.Param("tenant"))
+	})
+	r.Group("/v1").Use(mark("default v1"))
+	r.NoRoute(func(c *Context) {
+		c.String(http.StatusNotFound, "%s not found", c.Param("tenant"))
+	})
+
+	cases := []struct {
+		method, path string
+		code         int
+		body         string
+		trace        string
+	}{
+		{"GET", "/v1/hello", http.StatusOK, "hello acme", "engine tenant v1"},
+		{"GET", "/v1/unknown", http.StatusNotFound, "acme not found", "engine tenant v1"},
+		{"GET", "/unknown", http.StatusNotFound, "acme not found", "engine tenant"},
+		{"POST", "/v1/hello", http.StatusMethodNotAllowed, "405 METHOD NOT ALLOWED: /v1/hello\n", "engine tenant v1"},
+	}
+	for _, tc := range cases {
+		trace = nil
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.Host = "acme.example.com"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.code || w.Body.String() != tc.body {
+			t.Fatalf("%s %s: unexpected response %d %q", tc.method, tc.path, w.Code, w.Body.String())
+		}
+		if got := strings.Join(trace, " "); got != tc.trace {
+			t.Fatalf("%s %s: unexpected middlewares %q", tc.method, tc.path, got)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/v1/hello/", nil)
+	req.Host = "acme.example.com"
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/v1/hello" {
+		t.Fatalf("trailing slash should redirect inside the host, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestStripHostPort(t *testing.T) {
+	cases := map[string]string{
+		"example.com":      "example.com",
+		"example.com:8080": "example.com",
+		"example.com.":     "example.com",
+		"[::1]":            "[::1]",
+		"[::1]:8080":       "[::1]",
+	}
+	for host, want := range cases {
+		if got := stripHostPort(host); got != want {
+			t.Fatalf("stripHostPort(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
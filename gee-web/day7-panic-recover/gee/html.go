@@ -0,0 +1,266 @@
+package gee
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// HTMLRender 根据模板名和数据生成Render，Context.HTML通过它渲染，可以替换成其它模板引擎
+type HTMLRender interface {
+	Instance(name string, data interface{}) Render
+}
+
+/*
+	templateSet 是解析好的模板
+	sets为空时所有模板在同一个集合中，按模板名执行；
+	使用layout时每个页面和layout组成一个独立的集合，页面可以重新定义layout中的block而不会互相覆盖
+*/
+type templateSet struct {
+	root *template.Template
+	sets map[string]*template.Template
+}
+
+func (t *templateSet) instance(name string, data interface{}) Render {
+	if t.sets != nil {
+		return HTML{Template: t.sets[name], Name: name, Data: data}
+	}
+	return HTML{Template: t.root, Name: name, Data: data}
+}
+
+// htmlProduction 启动时解析一次，之后不再检查文件
+type htmlProduction struct {
+	templates *templateSet
+}
+
+func (r htmlProduction) Instance(name string, data interface{}) Render {
+	return r.templates.instance(name, data)
+}
+
+// htmlDebug 每次渲染前检查模板文件，有增删或者修改时间变化时重新解析，改模板不用重启
+type htmlDebug struct {
+	loader *htmlLoader
+
+	mu        sync.Mutex
+	templates *templateSet
+	modTimes  map[string]time.Time
+}
+
+func (r *htmlDebug) Instance(name string, data interface{}) Render {
+	templates, err := r.load()
+	if err != nil {
+		return errorRender{err}
+	}
+	return templates.instance(name, data)
+}
+
+func (r *htmlDebug) load() (*templateSet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	modTimes, err := r.loader.modTimes()
+	if err != nil {
+		return nil, err
+	}
+	if r.templates != nil && sameModTimes(modTimes, r.modTimes) {
+		return r.templates, nil
+	}
+	templates, err := r.loader.load()
+	if err != nil {
+		return nil, err
+	}
+	r.templates, r.modTimes = templates, modTimes
+	return templates, nil
+}
+
+func sameModTimes(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for file, t := range a {
+		if !b[file].Equal(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// errorRender 模板加载失败时，由Context.Render返回500
+type errorRender struct {
+	err error
+}
+
+func (r errorRender) Render(w http.ResponseWriter) error {
+	return r.err
+}
+
+func (r errorRender) WriteContentType(w http.ResponseWriter) {}
+
+// htmlLoader 记录模板从哪里加载，debug模式下重新加载、SetFuncMap之后重新解析都用它
+type htmlLoader struct {
+	fsys     fs.FS    // 为空时从本地文件加载
+	patterns []string // 模板文件的glob模式
+	layouts  []string // 不为空时，patterns匹配到的每个页面和layouts组成一个独立的集合
+	funcMap  template.FuncMap
+}
+
+func (l *htmlLoader) glob(patterns []string) ([]string, error) {
+	var files []string
+	for _, pattern := range patterns {
+		var matches []string
+		var err error
+		if l.fsys != nil {
+			matches, err = fs.Glob(l.fsys, pattern)
+		} else {
+			matches, err = filepath.Glob(pattern)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("gee: pattern %q matches no files", pattern)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+func (l *htmlLoader) parse(t *template.Template, files []string) (*template.Template, error) {
+	if l.fsys != nil {
+		return t.ParseFS(l.fsys, files...)
+	}
+	return t.ParseFiles(files...)
+}
+
+// 模板名为文件名，例如 templates/index.tmpl 的模板名为 index.tmpl
+func (l *htmlLoader) load() (*templateSet, error) {
+	pages, err := l.glob(l.patterns)
+	if err != nil {
+		return nil, err
+	}
+	if len(l.layouts) == 0 {
+		root, err := l.parse(template.New("").Funcs(l.funcMap), pages)
+		if err != nil {
+			return nil, err
+		}
+		return &templateSet{root: root}, nil
+	}
+
+	layouts, err := l.glob(l.layouts)
+	if err != nil {
+		return nil, err
+	}
+	sets := make(map[string]*template.Template, len(pages))
+	for _, page := range pages {
+		name := filepath.Base(page)
+		if _, ok := sets[name]; ok {
+			return nil, fmt.Errorf("gee: duplicate template %q", name)
+		}
+		// 先解析layout，页面中的define才能覆盖layout中的block
+		t, err := l.parse(template.New(name).Funcs(l.funcMap), append(layouts[:len(layouts):len(layouts)], page))
+		if err != nil {
+			return nil, err
+		}
+		sets[name] = t
+	}
+	return &templateSet{sets: sets}, nil
+}
+
+func (l *htmlLoader) modTimes() (map[string]time.Time, error) {
+	files, err := l.glob(append(l.patterns[:len(l.patterns):len(l.patterns)], l.layouts...))
+	if err != nil {
+		return nil, err
+	}
+	modTimes := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		var fi fs.FileInfo
+		if l.fsys != nil {
+			fi, err = fs.Stat(l.fsys, file)
+		} else {
+			fi, err = os.Stat(file)
+		}
+		if err != nil {
+			return nil, err
+		}
+		modTimes[file] = fi.ModTime()
+	}
+	return modTimes, nil
+}
+
+/*
+	模板加载
+	release模式下解析一次，出错时panic；debug模式下文件变化后自动重新解析。
+	SetFuncMap可以在加载之后调用，会用新的funcMap重新解析
+*/
+func (engine *Engine) loadHTML(loader *htmlLoader) {
+	loader.funcMap = engine.templateFuncs()
+	engine.htmlLoader = loader
+	if IsDebugging() {
+		render := &htmlDebug{loader: loader}
+		if _, err := render.load(); err != nil {
+			panic(err)
+		}
+		engine.HTMLRender = render
+		return
+	}
+	templates, err := loader.load()
+	if err != nil {
+		panic(err)
+	}
+	engine.HTMLRender = htmlProduction{templates: templates}
+}
+
+// 模板中可以用 {{url "doc" "lang" "go"}} 生成命名路由的路径，funcMap中的同名函数优先
+func (engine *Engine) templateFuncs() template.FuncMap {
+	funcMap := template.FuncMap{"url": engine.URL}
+	for name, fn := range engine.funcMap {
+		funcMap[name] = fn
+	}
+	return funcMap
+}
+
+func (engine *Engine) SetFuncMap(funcMap template.FuncMap) {
+	engine.funcMap = funcMap
+	if engine.htmlLoader != nil {
+		engine.loadHTML(engine.htmlLoader)
+	}
+}
+
+// LoadHTMLGlob 加载匹配pattern的模板文件，例如 templates/*
+func (engine *Engine) LoadHTMLGlob(pattern string) {
+	engine.loadHTML(&htmlLoader{patterns: []string{pattern}})
+}
+
+// LoadHTMLFiles 加载指定的模板文件
+func (engine *Engine) LoadHTMLFiles(files ...string) {
+	if len(files) == 0 {
+		panic(errors.New("gee: no template files"))
+	}
+	engine.loadHTML(&htmlLoader{patterns: files})
+}
+
+// LoadHTMLFS 从fsys中加载模板，例如 embed.FS，patterns是fs.Glob的模式
+func (engine *Engine) LoadHTMLFS(fsys fs.FS, patterns ...string) {
+	engine.loadHTML(&htmlLoader{fsys: fsys, patterns: patterns})
+}
+
+/*
+	LoadHTMLLayout 使用layout加载模板，layouts是layout和公共部分(partials)，pages是页面，都是glob模式
+	每个页面和layouts组成一个独立的集合，以页面的文件名作为模板名，例如
+	layouts/base.tmpl:  <title>{{block "title" .}}gee{{end}}</title>{{block "content" .}}{{end}}
+	pages/index.tmpl:   {{template "base.tmpl" .}}{{define "title"}}首页{{end}}{{define "content"}}...{{end}}
+	c.HTML(200, "index.tmpl", data)
+*/
+func (engine *Engine) LoadHTMLLayout(layouts, pages string) {
+	engine.loadHTML(&htmlLoader{layouts: []string{layouts}, patterns: []string{pages}})
+}
+
+// LoadHTMLLayoutFS 和LoadHTMLLayout一样，从fsys中加载
+func (engine *Engine) LoadHTMLLayoutFS(fsys fs.FS, layouts, pages string) {
+	engine.loadHTML(&htmlLoader{fsys: fsys, layouts: []string{layouts}, patterns: []string{pages}})
+}
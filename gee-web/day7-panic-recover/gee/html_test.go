@@ -0,0 +1,87 @@
+package gee
+
+import (
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestHTMLReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "index.tmpl")
+	ioutil.WriteFile(file, []byte(`<p>{{.}}</p>`), 0644)
+
+	r := New()
+	r.LoadHTMLFiles(file)
+	r.GET("/", func(c *Context) { c.HTML(http.StatusOK, "index.tmpl", "gee") })
+	if w := performRequest(r, "GET", "/"); w.Body.String() != "<p>gee</p>" {
+		t.Fatalf("unexpected body %q", w.Body.String())
+	}
+
+	// debug模式下修改模板不需要重启
+	ioutil.WriteFile(file, []byte(`<h1>{{upper .}}</h1>`), 0644)
+	os.Chtimes(file, time.Now(), time.Now().Add(time.Second))
+	r.SetFuncMap(template.FuncMap{"upper": strings.ToUpper})
+	if w := performRequest(r, "GET", "/"); w.Body.String() != "<h1>GEE</h1>" {
+		t.Fatalf("template should be reloaded, got %q", w.Body.String())
+	}
+
+	SetMode(ReleaseMode)
+	defer SetMode(DebugMode)
+	r.LoadHTMLGlob(filepath.Join(dir, "*.tmpl"))
+	ioutil.WriteFile(file, []byte(`changed`), 0644)
+	os.Chtimes(file, time.Now(), time.Now().Add(2*time.Second))
+	if w := performRequest(r, "GET", "/"); w.Body.String() != "<h1>GEE</h1>" {
+		t.Fatalf("release mode should not reload templates, got %q", w.Body.String())
+	}
+}
+
+func TestHTMLLayout(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layouts/base.tmpl":    {Data: []byte(`<title>{{block "title" .}}gee{{end}}</title>{{template "nav.tmpl"}}{{block "content" .}}{{end}}`)},
+		"layouts/nav.tmpl":     {Data: []byte(`<nav></nav>`)},
+		"pages/index.tmpl":     {Data: []byte(`{{template "base.tmpl" .}}{{define "content"}}<p>{{.}}</p>{{end}}`)},
+		"pages/about.tmpl":     {Data: []byte(`{{template "base.tmpl" .}}{{define "title"}}about{{end}}{{define "content"}}<p>about {{.}}</p>{{end}}`)},
+		"pages/broken.tmpl":    {Data: []byte(`<p>{{.Missing.Field}}</p>`)},
+		"templates/hello.tmpl": {Data: []byte(`hello {{.}}`)},
+	}
+	r := New()
+	r.LoadHTMLLayoutFS(fsys, "layouts/*.tmpl", "pages/*.tmpl")
+	r.GET("/:page", func(c *Context) {
+		c.HTML(http.StatusOK, c.Param("page")+".tmpl", H{"Missing": nil})
+	})
+
+	cases := map[string]string{
+		"/index": `<title>gee</title><nav></nav><p>map[Missing:&lt;nil&gt;]</p>`,
+		"/about": `<title>about</title><nav></nav><p>about map[Missing:&lt;nil&gt;]</p>`,
+	}
+	for path, body := range cases {
+		if w := performRequest(r, "GET", path); w.Code != http.StatusOK || w.Body.String() != body {
+			t.Fatalf("%s: unexpected response %d %q", path, w.Code, w.Body.String())
+		}
+	}
+
+	// 模板执行出错时不会输出一半的页面
+	for _, path := range []string{"/broken", "/none"} {
+		w := performRequest(r, "GET", path)
+		if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Type") != "application/json; charset=utf-8" ||
+			!strings.HasPrefix(w.Body.String(), `{"message":`) {
+			t.Fatalf("%s: unexpected response %d %q %v", path, w.Code, w.Body.String(), w.Header())
+		}
+	}
+
+	r.LoadHTMLFS(fsys, "templates/*.tmpl")
+	if w := performRequest(r, "GET", "/hello"); w.Body.String() != "hello map[Missing:&lt;nil&gt;]" {
+		t.Fatalf("unexpected body %q", w.Body.String())
+	}
+}
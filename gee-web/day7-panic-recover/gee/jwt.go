@@ -0,0 +1,201 @@
+package gee
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JWTClaimsKey 验证通过后，JWTClaims保存在Context中的key
+const JWTClaimsKey = "jwt_claims"
+
+// token验证失败的原因
+var (
+	ErrTokenMissing     = errors.New("gee: missing bearer token")
+	ErrTokenMalformed   = errors.New("gee: token is malformed")
+	ErrTokenAlgorithm   = errors.New("gee: token signing algorithm is not HS256")
+	ErrTokenSignature   = errors.New("gee: token signature is invalid")
+	ErrTokenExpired     = errors.New("gee: token is expired")
+	ErrTokenNotValidYet = errors.New("gee: token is not valid yet")
+	ErrTokenIssuer      = errors.New("gee: token issuer is invalid")
+	ErrTokenAudience    = errors.New("gee: token audience is invalid")
+)
+
+// JWTClaims token中的payload，数字按JSON的规则解码为float64
+type JWTClaims map[string]interface{}
+
+// Subject 返回sub，一般是用户ID
+func (claims JWTClaims) Subject() string {
+	sub, _ := claims["sub"].(string)
+	return sub
+}
+
+// JWTConfig 是JWTWithConfig的配置
+type JWTConfig struct {
+	// HS256的密钥
+	Secret []byte
+	// 不为空时，iss必须相等
+	Issuer string
+	// 不为空时，aud必须等于它或者包含它
+	Audience string
+	// 校验exp和nbf时允许的时钟误差
+	Leeway time.Duration
+	// WWW-Authenticate中的realm
+	Realm string
+}
+
+// JWT 验证Authorization: Bearer <token>中HS256签名的token
+func JWT(secret []byte) HandlerFunc {
+	return JWTWithConfig(JWTConfig{Secret: secret})
+}
+
+/*
+	JWTWithConfig 在本地验证HS256签名的JWT，不依赖第三方库
+	验证通过后claims保存在JWTClaimsKey中，sub保存在AuthUserKey中；
+	失败时返回401，WWW-Authenticate按RFC 6750带上错误原因
+*/
+func JWTWithConfig(conf JWTConfig) HandlerFunc {
+	if len(conf.Secret) == 0 {
+		panic("gee: JWT secret can not be empty")
+	}
+	realm := ""
+	if conf.Realm != "" {
+		realm = "realm=" + strconv.Quote(conf.Realm)
+	}
+
+	return func(c *Context) {
+		token := bearerToken(c.Req.Header.Get("Authorization"))
+		claims, err := ParseJWT(token, conf, time.Now())
+		if err != nil {
+			challenge := "Bearer"
+			if realm != "" {
+				challenge += " " + realm
+			}
+			if err != ErrTokenMissing {
+				if realm != "" {
+					challenge += ","
+				}
+				challenge += fmt.Sprintf(` error="invalid_token", error_description=%q`, strings.TrimPrefix(err.Error(), "gee: "))
+			}
+			c.SetHeader("WWW-Authenticate", challenge)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, H{"message": err.Error()})
+			return
+		}
+		c.Set(JWTClaimsKey, claims)
+		if sub := claims.Subject(); sub != "" {
+			c.Set(AuthUserKey, sub)
+		}
+		c.Next()
+	}
+}
+
+// 取出Bearer后面的token，scheme不区分大小写
+func bearerToken(header string) string {
+	const prefix = "bearer "
+	if len(header) > len(prefix) && strings.EqualFold(header[:len(prefix)], prefix) {
+		return strings.TrimSpace(header[len(prefix):])
+	}
+	return ""
+}
+
+// ParseJWT 验证token的签名和exp、nbf、iss、aud，返回其中的claims
+func ParseJWT(token string, conf JWTConfig, now time.Time) (JWTClaims, error) {
+	if token == "" {
+		return nil, ErrTokenMissing
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if header.Alg != "HS256" {
+		return nil, ErrTokenAlgorithm
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	mac := hmac.New(sha256.New, conf.Secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, ErrTokenSignature
+	}
+
+	var claims JWTClaims
+	if err := decodeSegment(parts[1], &claims); err != nil || claims == nil {
+		return nil, ErrTokenMalformed
+	}
+	if exp, ok := claims["exp"]; ok {
+		t, ok := numericDate(exp)
+		if !ok {
+			return nil, ErrTokenMalformed
+		}
+		if !now.Before(t.Add(conf.Leeway)) {
+			return nil, ErrTokenExpired
+		}
+	}
+	if nbf, ok := claims["nbf"]; ok {
+		t, ok := numericDate(nbf)
+		if !ok {
+			return nil, ErrTokenMalformed
+		}
+		if now.Add(conf.Leeway).Before(t) {
+			return nil, ErrTokenNotValidYet
+		}
+	}
+	if conf.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != conf.Issuer {
+			return nil, ErrTokenIssuer
+		}
+	}
+	if conf.Audience != "" && !hasAudience(claims["aud"], conf.Audience) {
+		return nil, ErrTokenAudience
+	}
+	return claims, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// NumericDate是从1970年开始的秒数，可以带小数
+func numericDate(v interface{}) (time.Time, bool) {
+	f, ok := v.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	sec := int64(f)
+	return time.Unix(sec, int64((f-float64(sec))*1e9)), true
+}
+
+// aud可以是字符串，也可以是字符串数组
+func hasAudience(aud interface{}, want string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == want
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok && s == want {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -1,19 +1,186 @@
 package gee
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
-// 记录请求到响应所花费的时间
-func Logger()HandlerFunc{
+
+// HeaderRequestID 是记录请求ID的头部
+const HeaderRequestID = "X-Request-ID"
+
+// LogFormatterParams 是一条访问日志包含的信息
+type LogFormatterParams struct {
+	Request   *http.Request
+	TimeStamp time.Time     // 请求处理完的时间
+	Status    int           // 响应状态码
+	Latency   time.Duration // 请求到响应所花费的时间
+	ClientIP  string
+	Method    string
+	Path      string // 请求的路径，带上?后面的参数
+	Route     string // 匹配到的路由，例如 /p/:lang/doc，没有匹配到时为空
+	UserAgent string
+	BodySize  int    // 响应体的字节数
+	RequestID string // 请求或响应中X-Request-ID头的值
+}
+
+// LogFormatter 把一次请求格式化成一行日志，不需要带换行符
+type LogFormatter func(params LogFormatterParams) string
+
+// LoggerConfig 是LoggerWithConfig的配置
+type LoggerConfig struct {
+	// 日志格式，默认为LogfmtFormatter
+	Formatter LogFormatter
+	// 日志写到哪里，默认为os.Stderr，和log包一样
+	Output io.Writer
+	// 这些路径的请求不记录日志，例如健康检查 /healthz
+	SkipPaths []string
+}
+
+// 记录请求到响应所花费的时间，和以前一样通过log包输出 [200] /p/go/doc in 1ms
+// 需要logfmt、JSON格式时使用LoggerWithConfig
+func Logger() HandlerFunc {
+	return LoggerWithConfig(LoggerConfig{
+		Formatter: defaultLogFormatter,
+		Output:    stdLogWriter{},
+	})
+}
+
+func defaultLogFormatter(p LogFormatterParams) string {
+	return fmt.Sprintf("[%d] %s in %v", p.Status, p.Request.RequestURI, p.Latency)
+}
+
+// 把日志交给log包，带上log包设置的时间等前缀
+type stdLogWriter struct{}
+
+func (stdLogWriter) Write(p []byte) (int, error) {
+	log.Print(string(p))
+	return len(p), nil
+}
+
+// LoggerWithConfig 按配置记录访问日志，每个请求输出一行
+func LoggerWithConfig(conf LoggerConfig) HandlerFunc {
+	formatter := conf.Formatter
+	if formatter == nil {
+		formatter = LogfmtFormatter
+	}
+	out := conf.Output
+	if out == nil {
+		out = os.Stderr
+	}
+	skip := make(map[string]bool, len(conf.SkipPaths))
+	for _, path := range conf.SkipPaths {
+		skip[path] = true
+	}
+	var mu sync.Mutex // 多个请求同时写日志时，保证每一行是完整的
+
 	return func(c *Context) {
 		// 开始时间
 		startTime := time.Now()
+		path := c.Req.URL.Path
 
 		c.Next()
 
+		if skip[path] {
+			return
+		}
 		// 结束时间
-		log.Printf("[%d] %s in %v", c.StatusCode, c.Req.RequestURI, time.Since(startTime))
+		params := LogFormatterParams{
+			Request:   c.Req,
+			TimeStamp: time.Now(),
+			Status:    c.Writer.Status(),
+			ClientIP:  c.ClientIP(),
+			Method:    c.Req.Method,
+			Path:      c.Req.URL.RequestURI(),
+			Route:     c.FullPath(),
+			UserAgent: c.Req.UserAgent(),
+			BodySize:  c.Writer.Size(),
+			RequestID: c.Req.Header.Get(HeaderRequestID),
+		}
+		params.Latency = params.TimeStamp.Sub(startTime)
+		if params.BodySize < 0 {
+			params.BodySize = 0
+		}
+		if params.RequestID == "" {
+			params.RequestID = c.Writer.Header().Get(HeaderRequestID)
+		}
+
+		line := formatter(params) + "\n"
+		mu.Lock()
+		io.WriteString(out, line)
+		mu.Unlock()
+	}
+}
+
+// LogfmtFormatter 输出logfmt格式的日志，例如
+// time=2021-03-01T12:00:00+08:00 status=200 method=GET path=/p/go/doc route=/p/:lang/doc ...
+func LogfmtFormatter(p LogFormatterParams) string {
+	var b strings.Builder
+	pairs := [][2]string{
+		{"time", p.TimeStamp.Format(time.RFC3339)},
+		{"status", strconv.Itoa(p.Status)},
+		{"method", p.Method},
+		{"path", p.Path},
+		{"route", p.Route},
+		{"ip", p.ClientIP},
+		{"latency", p.Latency.String()},
+		{"size", strconv.Itoa(p.BodySize)},
+		{"user_agent", p.UserAgent},
+		{"request_id", p.RequestID},
+	}
+	for i, pair := range pairs {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(pair[0])
+		b.WriteByte('=')
+		b.WriteString(logfmtValue(pair[1]))
+	}
+	return b.String()
+}
+
+// 值为空或者含有空格、引号、等号时加上引号
+func logfmtValue(v string) string {
+	if v == "" || strings.ContainsAny(v, " \t\"=\\") || !strconv.CanBackquote(v) {
+		return strconv.Quote(v)
+	}
+	return v
+}
 
+// JSONLogFormatter 每个请求输出一个JSON对象
+func JSONLogFormatter(p LogFormatterParams) string {
+	data, err := json.Marshal(struct {
+		Time      string `json:"time"`
+		Status    int    `json:"status"`
+		Method    string `json:"method"`
+		Path      string `json:"path"`
+		Route     string `json:"route"`
+		ClientIP  string `json:"ip"`
+		Latency   string `json:"latency"`
+		BodySize  int    `json:"size"`
+		UserAgent string `json:"user_agent"`
+		RequestID string `json:"request_id"`
+	}{
+		Time:      p.TimeStamp.Format(time.RFC3339),
+		Status:    p.Status,
+		Method:    p.Method,
+		Path:      p.Path,
+		Route:     p.Route,
+		ClientIP:  p.ClientIP,
+		Latency:   p.Latency.String(),
+		BodySize:  p.BodySize,
+		UserAgent: p.UserAgent,
+		RequestID: p.RequestID,
+	})
+	if err != nil {
+		return fmt.Sprintf(`{"error":%q}`, err.Error())
 	}
+	return string(data)
 }
@@ -0,0 +1,97 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	flags := log.Flags()
+	log.SetFlags(0)
+	defer func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(flags)
+	}()
+
+	r := New()
+	r.Use(Logger())
+	r.GET("/p/:lang/doc", func(c *Context) {
+		c.String(http.StatusCreated, "doc")
+	})
+	buf.Reset()
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/p/go/doc?v=1", nil))
+
+	// Logger保持原来的格式，通过log包输出
+	if line := buf.String(); !strings.HasPrefix(line, "[201] /p/go/doc?v=1 in ") || strings.Count(line, "\n") != 1 {
+		t.Fatalf("unexpected log line %q", line)
+	}
+}
+
+func TestLoggerWithConfig(t *testing.T) {
+	var buf bytes.Buffer
+	r := New()
+	r.Use(LoggerWithConfig(LoggerConfig{
+		Formatter: JSONLogFormatter,
+		Output:    &buf,
+		SkipPaths: []string{"/healthz"},
+	}))
+	r.GET("/p/:lang/doc", func(c *Context) {
+		// 直接写入Writer，日志中的状态码和大小也要正确
+		c.Writer.Write([]byte("doc"))
+	})
+	r.GET("/healthz", func(c *Context) {})
+
+	r.ForwardedByClientIP = true
+	req := httptest.NewRequest("GET", "/p/go/doc?v=1", nil)
+	req.Header.Set("User-Agent", "gee-test")
+	req.Header.Set(HeaderRequestID, "req-1")
+	req.Header.Set("X-Forwarded-For", "10.0.0.1, 10.0.0.2")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("should log exactly one line, got %q", buf.String())
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"status":     float64(http.StatusOK),
+		"method":     "GET",
+		"path":       "/p/go/doc?v=1",
+		"route":      "/p/:lang/doc",
+		"ip":         "10.0.0.1",
+		"size":       float64(3),
+		"user_agent": "gee-test",
+		"request_id": "req-1",
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Fatalf("%s should be %v, got %v", key, value, entry[key])
+		}
+	}
+}
+
+func TestLogfmtFormatter(t *testing.T) {
+	line := LogfmtFormatter(LogFormatterParams{
+		Status:    404,
+		Method:    "GET",
+		Path:      "/none",
+		UserAgent: "curl/7.64 (x86)",
+	})
+	for _, field := range []string{"status=404", "path=/none", `route=""`, `user_agent="curl/7.64 (x86)"`} {
+		if !strings.Contains(line, field) {
+			t.Fatalf("%q should contain %s", line, field)
+		}
+	}
+}
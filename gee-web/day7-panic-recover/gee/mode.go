@@ -0,0 +1,41 @@
+package gee
+
+import "os"
+
+// 运行模式，debug模式下会输出更多调试信息，线上应该使用release模式
+const (
+	DebugMode   = "debug"
+	ReleaseMode = "release"
+	TestMode    = "test"
+)
+
+// EnvGeeMode 是设置运行模式的环境变量
+const EnvGeeMode = "GEE_MODE"
+
+var geeMode = DebugMode
+
+func init() {
+	SetMode(os.Getenv(EnvGeeMode))
+}
+
+// SetMode 设置运行模式，为空时使用debug模式
+func SetMode(value string) {
+	switch value {
+	case DebugMode, "":
+		geeMode = DebugMode
+	case ReleaseMode, TestMode:
+		geeMode = value
+	default:
+		panic("gee: unknown mode " + value)
+	}
+}
+
+// Mode 返回当前的运行模式
+func Mode() string {
+	return geeMode
+}
+
+// IsDebugging 是否处于debug模式
+func IsDebugging() bool {
+	return geeMode == DebugMode
+}
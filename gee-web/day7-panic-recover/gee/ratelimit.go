@@ -0,0 +1,196 @@
+package gee
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitResult 是一次取令牌的结果
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int           // 桶的容量
+	Remaining  int           // 剩余的令牌数
+	Reset      time.Duration // 多久之后令牌桶重新装满
+	RetryAfter time.Duration // 被拒绝时，多久之后有新的令牌
+}
+
+/*
+	RateLimitStore 保存每个key的令牌桶，默认是进程内的实现，
+	多个实例共享限流时可以换成基于gee-cache等外部存储的实现
+*/
+type RateLimitStore interface {
+	// Take 从key的桶中取一个令牌，桶的容量为limit，每period补满
+	Take(key string, limit int, period time.Duration) (RateLimitResult, error)
+}
+
+// RateLimitKeyFunc 决定请求计入哪个桶，返回空字符串时不限流
+type RateLimitKeyFunc func(c *Context) string
+
+// RateLimitConfig 是RateLimitWithConfig的配置
+type RateLimitConfig struct {
+	// 每个Period最多Limit个请求，也是允许的突发请求数
+	Limit  int
+	Period time.Duration
+	// 默认按客户端IP限流
+	KeyFunc RateLimitKeyFunc
+	// 默认为NewMemoryRateLimitStore(0)
+	Store RateLimitStore
+	// 被限流时的响应，默认返回429 {"message": "Too Many Requests"}
+	Handler HandlerFunc
+}
+
+// RateLimit 按客户端IP限流，每个period最多limit个请求
+func RateLimit(limit int, period time.Duration) HandlerFunc {
+	return RateLimitWithConfig(RateLimitConfig{Limit: limit, Period: period})
+}
+
+/*
+	RateLimitWithConfig 令牌桶限流，响应带上RateLimit-Limit、RateLimit-Remaining和RateLimit-Reset，
+	被拒绝时再带上Retry-After，时间都是向上取整的秒数。
+	Store出错时不限流，避免存储故障导致整个服务不可用
+*/
+func RateLimitWithConfig(conf RateLimitConfig) HandlerFunc {
+	if conf.Limit <= 0 || conf.Period <= 0 {
+		panic("gee: rate limit and period must be positive")
+	}
+	if conf.KeyFunc == nil {
+		conf.KeyFunc = KeyByClientIP
+	}
+	if conf.Store == nil {
+		conf.Store = NewMemoryRateLimitStore(0)
+	}
+	if conf.Handler == nil {
+		conf.Handler = func(c *Context) {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, H{"message": http.StatusText(http.StatusTooManyRequests)})
+		}
+	}
+
+	return func(c *Context) {
+		key := conf.KeyFunc(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+		res, err := conf.Store.Take(key, conf.Limit, conf.Period)
+		if err != nil {
+			c.Next()
+			return
+		}
+		header := c.Writer.Header()
+		header.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		header.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		header.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+		if !res.Allowed {
+			header.Set("Retry-After", ceilSeconds(res.RetryAfter))
+			c.Abort()
+			conf.Handler(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// KeyByClientIP 按客户端IP限流，默认使用连接的RemoteAddr，
+// 只有打开Engine.ForwardedByClientIP时才使用X-Forwarded-For，否则客户端换一个头部就能绕过限流
+func KeyByClientIP(c *Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// KeyByHeader 按请求头限流，例如 X-API-Key，没有这个头部时按IP
+func KeyByHeader(name string) RateLimitKeyFunc {
+	return func(c *Context) string {
+		if v := c.Req.Header.Get(name); v != "" {
+			return "header:" + v
+		}
+		return KeyByClientIP(c)
+	}
+}
+
+// KeyByUser 按BasicAuth或者JWT认证的用户限流，需要放在认证中间件后面，未认证时按IP
+func KeyByUser(c *Context) string {
+	if user := c.GetString(AuthUserKey); user != "" {
+		return "user:" + user
+	}
+	return KeyByClientIP(c)
+}
+
+// KeyByRoute 每个路由共用一个桶，例如 /p/:lang/doc，没有匹配到路由时按路径
+func KeyByRoute(c *Context) string {
+	if route := c.FullPath(); route != "" {
+		return "route:" + c.Method + " " + route
+	}
+	return "path:" + c.Method + " " + c.Path
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// memoryRateLimitStore 进程内的令牌桶，空闲的桶在Take时顺便清理
+type memoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	idle      time.Duration
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryRateLimitStore 创建进程内的RateLimitStore，超过idle没有请求的桶会被清理，默认10分钟
+// idle应该大于Period，否则桶被清理后会提前装满
+func NewMemoryRateLimitStore(idle time.Duration) RateLimitStore {
+	if idle <= 0 {
+		idle = 10 * time.Minute
+	}
+	return &memoryRateLimitStore{
+		buckets: make(map[string]*tokenBucket),
+		idle:    idle,
+		now:     time.Now,
+	}
+}
+
+func (s *memoryRateLimitStore) Take(key string, limit int, period time.Duration) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastSweep) >= s.idle {
+		s.sweep(now)
+	}
+	// 每纳秒补充的令牌数
+	rate := float64(limit) / float64(period)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(limit), last: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit), b.tokens+float64(now.Sub(b.last))*rate)
+	b.last = now
+
+	res := RateLimitResult{Limit: limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - b.tokens) / rate)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = time.Duration((float64(limit) - b.tokens) / rate)
+	return res, nil
+}
+
+func (s *memoryRateLimitStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if now.Sub(b.last) >= s.idle {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,94 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	store := NewMemoryRateLimitStore(time.Minute).(*memoryRateLimitStore)
+	store.now = func() time.Time { return now }
+
+	r := New()
+	r.Use(RateLimitWithConfig(RateLimitConfig{Limit: 2, Period: 10 * time.Second, KeyFunc: KeyByHeader("X-API-Key"), Store: store}))
+	r.GET("/", func(c *Context) { c.String(http.StatusOK, "ok") })
+
+	request := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i, remaining := range []string{"1", "0"} {
+		w := request("a")
+		if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Remaining") != remaining {
+			t.Fatalf("request %d: unexpected response %d %v", i, w.Code, w.Header())
+		}
+	}
+	w := request("a")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "5" || w.Header().Get("RateLimit-Reset") != "10" {
+		t.Fatalf("third request should be limited, got %d %v", w.Code, w.Header())
+	}
+	// 其它key不受影响
+	if w := request("b"); w.Code != http.StatusOK {
+		t.Fatalf("key b should not be limited, got %d", w.Code)
+	}
+
+	// 5秒后补充了一个令牌
+	now = now.Add(5 * time.Second)
+	if w := request("a"); w.Code != http.StatusOK {
+		t.Fatalf("bucket should be refilled, got %d", w.Code)
+	}
+
+	// 空闲的桶被清理
+	now = now.Add(2 * time.Minute)
+	request("c")
+	if len(store.buckets) != 1 {
+		t.Fatalf("idle buckets should be evicted, got %d", len(store.buckets))
+	}
+}
+
+func TestRateLimitForwardedFor(t *testing.T) {
+	r := New()
+	r.Use(RateLimit(1, time.Hour))
+	r.GET("/", func(c *Context) { c.String(http.StatusOK, "ok") })
+
+	// 伪造X-Forwarded-For不能得到新的桶
+	for i, forwarded := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", forwarded)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if i > 0 && w.Code != http.StatusTooManyRequests {
+			t.Fatalf("request %d should be limited, got %d", i, w.Code)
+		}
+	}
+}
+
+func TestRateLimitKeyFuncs(t *testing.T) {
+	r := New()
+	var keys []string
+	r.Use(func(c *Context) {
+		c.Set(AuthUserKey, "geektutu")
+		c.Next()
+	})
+	r.GET("/p/:lang", func(c *Context) {
+		keys = append(keys, KeyByClientIP(c), KeyByUser(c), KeyByRoute(c))
+	})
+	req := httptest.NewRequest("GET", "/p/go", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	expected := []string{"ip:10.0.0.1", "user:geektutu", "route:GET /p/:lang"}
+	for i := range expected {
+		if keys[i] != expected[i] {
+			t.Fatalf("key %d should be %q, got %q", i, expected[i], keys[i])
+		}
+	}
+}
@@ -1,43 +1,116 @@
 package gee
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"os"
 	"runtime"
 	"strings"
+	"syscall"
 )
 
+// RecoveryFunc 在panic被捕获之后生成响应，err是recover()的返回值
+type RecoveryFunc func(c *Context, err interface{})
+
+// RecoveryConfig 是RecoveryWithConfig的配置
+type RecoveryConfig struct {
+	// 自定义响应，默认返回500 {"message": "Internal Server Error"}
+	Handler RecoveryFunc
+	// panic的日志写到哪里，默认为os.Stderr
+	Output io.Writer
+	// 在响应中带上调用栈，只在debug模式下生效，使用自定义Handler时无效
+	StackInResponse bool
+}
+
 // 中间件，负责处理错误，向Context返回服务器错误
 func Recovery() HandlerFunc {
+	return RecoveryWithConfig(RecoveryConfig{})
+}
+
+/*
+	RecoveryWithConfig 按配置捕获panic
+	客户端已经断开连接(broken pipe、connection reset)时无法再写入响应，只记录日志并中止；
+	响应头已经发送时也不能再返回500，同样只中止后面的handler；
+	http.ErrAbortHandler 不处理，重新panic让net/http中止响应并关闭连接
+*/
+func RecoveryWithConfig(conf RecoveryConfig) HandlerFunc {
+	out := conf.Output
+	if out == nil {
+		out = os.Stderr
+	}
+	logger := log.New(out, "[Recovery] ", log.LstdFlags)
+
 	return func(c *Context) {
 		defer func() {
 			// 捕获panic
 			if err := recover(); err != nil {
+				// http.ErrAbortHandler 是handler主动中止响应，交给net/http关闭连接
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
 				message := fmt.Sprintf("%s", err)
-				log.Printf("%s\n\n", trace(message))
-				c.Fail(http.StatusInternalServerError, "Internal Server Error")
+				if isBrokenConnection(err) {
+					logger.Printf("broken connection: %s %s: %s\n\n", c.Method, c.Path, message)
+					c.Abort()
+					return
+				}
+				stack := trace(message)
+				logger.Printf("%s\n\n", stack)
+				if c.Writer.Written() {
+					// 响应已经写出去一部分，只能中止
+					c.Abort()
+					return
+				}
+				if conf.Handler != nil {
+					c.Abort()
+					conf.Handler(c, err)
+					return
+				}
+				body := H{"message": "Internal Server Error"}
+				if conf.StackInResponse && IsDebugging() {
+					body["stack"] = stack
+				}
+				c.AbortWithStatusJSON(http.StatusInternalServerError, body)
 			}
 		}()
 		c.Next()
 	}
 }
 
+// 写响应时对端已经关闭了连接，只认网络连接上的EPIPE、ECONNRESET，
+// 应用自己的错误即使提到broken pipe也按普通的panic处理
+func isBrokenConnection(err interface{}) bool {
+	e, ok := err.(error)
+	if !ok {
+		return false
+	}
+	var opErr *net.OpError
+	var sysErr *os.SyscallError
+	if !errors.As(e, &opErr) && !errors.As(e, &sysErr) {
+		return false
+	}
+	return errors.Is(e, syscall.EPIPE) || errors.Is(e, syscall.ECONNRESET)
+}
+
 // print stack trace for debug
 // Callers 用来返回调用栈的程序计数器,
 // 第 0 个 Caller 是 Callers 本身，第 1 个是上一层 trace，第 2 个是再上一层的 defer func。
 // 因此，为了日志简洁一点，我们跳过了前 3 个 Caller。
 // 接下来，通过 runtime.FuncForPC(pc) 获取对应的函数，
 // 再通过 fn.FileLine(pc) 获取到调用该函数的文件名和行号，打印在日志中。
-func trace(message string)string{
+func trace(message string) string {
 	var pcs [32]uintptr
-	n := runtime.Callers(3,pcs[:])
+	n := runtime.Callers(3, pcs[:])
 
 	var str strings.Builder
-	str.WriteString(message+"\nTraceback:")
-	for _,pc := range pcs[:n]{
+	str.WriteString(message + "\nTraceback:")
+	for _, pc := range pcs[:n] {
 		fn := runtime.FuncForPC(pc)
-		file,line := fn.FileLine(pc)
+		file, line := fn.FileLine(pc)
 		str.WriteString(fmt.Sprintf("\n\t%s:%d", file, line))
 	}
 	return str.String()
@@ -0,0 +1,113 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestRecoveryWithConfig(t *testing.T) {
+	var buf bytes.Buffer
+	r := New()
+	r.Use(RecoveryWithConfig(RecoveryConfig{
+		Output: &buf,
+		Handler: func(c *Context, err interface{}) {
+			c.JSON(http.StatusServiceUnavailable, H{"error": err})
+		},
+	}))
+	r.GET("/panic", func(c *Context) {
+		panic("boom")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/panic", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != `{"error":"boom"}` {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+	if !strings.Contains(buf.String(), "boom\nTraceback:") {
+		t.Fatalf("panic should be logged with stack, got %q", buf.String())
+	}
+}
+
+func TestRecoveryStackInResponse(t *testing.T) {
+	defer SetMode(Mode())
+	r := New()
+	r.Use(RecoveryWithConfig(RecoveryConfig{Output: new(bytes.Buffer), StackInResponse: true}))
+	r.GET("/panic", func(c *Context) {
+		panic("boom")
+	})
+
+	for mode, hasStack := range map[string]bool{DebugMode: true, ReleaseMode: false} {
+		SetMode(mode)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/panic", nil))
+		var body map[string]string
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != http.StatusInternalServerError || (body["stack"] != "") != hasStack {
+			t.Fatalf("%s mode: unexpected response %d %v", mode, w.Code, body)
+		}
+	}
+}
+
+func TestRecoveryBrokenConnection(t *testing.T) {
+	var buf bytes.Buffer
+	r := New()
+	r.Use(RecoveryWithConfig(RecoveryConfig{Output: &buf}))
+	r.GET("/broken", func(c *Context) {
+		panic(&net.OpError{Op: "write", Err: &os.SyscallError{Syscall: "write", Err: syscall.EPIPE}})
+	})
+	r.GET("/written", func(c *Context) {
+		c.String(http.StatusOK, "partial")
+		panic("boom")
+	})
+	r.GET("/app", func(c *Context) {
+		// 应用自己的错误，不是客户端断开
+		panic(errors.New("redis: write: broken pipe"))
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/broken", nil))
+	if w.Body.Len() != 0 || strings.Contains(buf.String(), "Traceback") {
+		t.Fatalf("broken connection shouldn't write a response, got %q", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/written", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "partial" {
+		t.Fatalf("written response shouldn't be changed, got %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/app", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("application error mentioning broken pipe should return 500, got %d", w.Code)
+	}
+}
+
+func TestRecoveryErrAbortHandler(t *testing.T) {
+	var buf bytes.Buffer
+	r := New()
+	r.Use(RecoveryWithConfig(RecoveryConfig{Output: &buf}))
+	r.GET("/abort", func(c *Context) {
+		panic(http.ErrAbortHandler)
+	})
+
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Fatalf("http.ErrAbortHandler should be re-panicked, got %v", err)
+		}
+		if buf.Len() != 0 {
+			t.Fatalf("http.ErrAbortHandler shouldn't be logged, got %q", buf.String())
+		}
+	}()
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abort", nil))
+}
@@ -0,0 +1,269 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"regexp"
+	"sort"
+
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// Render 把数据按某种格式写到response中，Context.String、JSON、HTML等都是通过Render实现的
+type Render interface {
+	// Render 写入响应体
+	Render(w http.ResponseWriter) error
+	// WriteContentType 在写入状态码之前设置Content-Type
+	WriteContentType(w http.ResponseWriter)
+}
+
+// 没有设置过Content-Type时才设置，handler可以提前用SetHeader覆盖
+func writeContentType(w http.ResponseWriter, value string) {
+	header := w.Header()
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", value)
+	}
+}
+
+// String 渲染text/plain，Data为空时Format原样输出
+type String struct {
+	Format string
+	Data   []interface{}
+}
+
+func (r String) Render(w http.ResponseWriter) error {
+	var err error
+	if len(r.Data) > 0 {
+		_, err = fmt.Fprintf(w, r.Format, r.Data...)
+	} else {
+		_, err = w.Write([]byte(r.Format))
+	}
+	return err
+}
+
+func (r String) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, MIMEPlain+"; charset=utf-8")
+}
+
+// JSON 把Data本身编码成JSON
+type JSON struct {
+	Data interface{}
+}
+
+func (r JSON) Render(w http.ResponseWriter) error {
+	data, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (r JSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, MIMEJSON+"; charset=utf-8")
+}
+
+// IndentedJSON 带缩进的JSON，方便直接阅读
+type IndentedJSON struct {
+	Data interface{}
+}
+
+func (r IndentedJSON) Render(w http.ResponseWriter) error {
+	data, err := json.MarshalIndent(r.Data, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (r IndentedJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, MIMEJSON+"; charset=utf-8")
+}
+
+// SecureJSON 数据是JSON数组时在前面加上Prefix，防止JSON劫持
+type SecureJSON struct {
+	Prefix string
+	Data   interface{}
+}
+
+func (r SecureJSON) Render(w http.ResponseWriter) error {
+	data, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(data, []byte("[")) && bytes.HasSuffix(data, []byte("]")) {
+		if _, err = w.Write([]byte(r.Prefix)); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (r SecureJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, MIMEJSON+"; charset=utf-8")
+}
+
+// JSONP 把JSON包在Callback函数调用中，Callback为空时和JSON一样
+type JSONP struct {
+	Callback string
+	Data     interface{}
+}
+
+// Callback只能是JS的标识符，可以用.访问属性，例如 jQuery.cb，否则可以注入任意脚本
+var jsonpCallbackRegexp = regexp.MustCompile(`^[A-Za-z_$][0-9A-Za-z_$.]*$`)
+
+// ErrInvalidCallback 是JSONP的Callback不是合法的JS标识符时返回的错误
+var ErrInvalidCallback = errors.New("gee: invalid JSONP callback")
+
+// 检查callback是否可以用在JSONP中
+func validJSONPCallback(callback string) bool {
+	return jsonpCallbackRegexp.MatchString(callback)
+}
+
+func (r JSONP) Render(w http.ResponseWriter) error {
+	data, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	if r.Callback == "" {
+		_, err = w.Write(data)
+		return err
+	}
+	if !validJSONPCallback(r.Callback) {
+		return ErrInvalidCallback
+	}
+	_, err = fmt.Fprintf(w, "%s(%s);", r.Callback, data)
+	return err
+}
+
+func (r JSONP) WriteContentType(w http.ResponseWriter) {
+	// 浏览器不能把响应猜测成其它类型，例如当成HTML执行
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if r.Callback == "" {
+		writeContentType(w, MIMEJSON+"; charset=utf-8")
+		return
+	}
+	writeContentType(w, MIMEJavaScript+"; charset=utf-8")
+}
+
+// XML 把Data编码成XML，H也可以编码
+type XML struct {
+	Data interface{}
+}
+
+func (r XML) Render(w http.ResponseWriter) error {
+	return xml.NewEncoder(w).Encode(r.Data)
+}
+
+func (r XML) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, MIMEXML+"; charset=utf-8")
+}
+
+// YAML 把Data编码成YAML
+type YAML struct {
+	Data interface{}
+}
+
+func (r YAML) Render(w http.ResponseWriter) error {
+	data, err := yaml.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (r YAML) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, MIMEYAML+"; charset=utf-8")
+}
+
+// ProtoBuf 把Data编码成protobuf，Data必须是proto.Message
+type ProtoBuf struct {
+	Data interface{}
+}
+
+func (r ProtoBuf) Render(w http.ResponseWriter) error {
+	msg, ok := r.Data.(proto.Message)
+	if !ok {
+		return fmt.Errorf("gee: %T is not a proto.Message", r.Data)
+	}
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (r ProtoBuf) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, MIMEPROTOBUF)
+}
+
+// Data 原样写入字节数据，ContentType为空时不设置Content-Type
+type Data struct {
+	ContentType string
+	Data        []byte
+}
+
+func (r Data) Render(w http.ResponseWriter) error {
+	_, err := w.Write(r.Data)
+	return err
+}
+
+func (r Data) WriteContentType(w http.ResponseWriter) {
+	if r.ContentType != "" {
+		writeContentType(w, r.ContentType)
+	}
+}
+
+// HTML 根据模板文件名选择模板进行渲染，先渲染到缓冲区，模板执行出错时还可以返回500
+type HTML struct {
+	Template *template.Template
+	Name     string
+	Data     interface{}
+}
+
+func (r HTML) Render(w http.ResponseWriter) error {
+	if r.Template == nil {
+		return fmt.Errorf("gee: html template %q is not loaded", r.Name)
+	}
+	var buf bytes.Buffer
+	if err := r.Template.ExecuteTemplate(&buf, r.Name, r.Data); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (r HTML) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, MIMEHTML+"; charset=utf-8")
+}
+
+// MarshalXML 让H可以编码成XML，键按字母序输出
+// 例如 H{"name": "gee"} 编码为 <map><name>gee</name></map>
+func (h H) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: "map"}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(h))
+	for key := range h {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		elem := xml.StartElement{Name: xml.Name{Local: key}}
+		if err := e.EncodeElement(h[key], elem); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
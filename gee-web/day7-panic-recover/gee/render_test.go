@@ -0,0 +1,102 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func newRenderEngine() *Engine {
+	r := New()
+	r.GET("/json", func(c *Context) { c.JSON(http.StatusOK, H{"name": "gee"}) })
+	r.GET("/indented", func(c *Context) { c.IndentedJSON(http.StatusOK, H{"name": "gee"}) })
+	r.GET("/secure", func(c *Context) { c.SecureJSON(http.StatusOK, []string{"gee"}) })
+	r.GET("/jsonp", func(c *Context) { c.JSONP(http.StatusOK, H{"name": "gee"}) })
+	r.GET("/xml", func(c *Context) { c.XML(http.StatusOK, H{"name": "gee"}) })
+	r.GET("/yaml", func(c *Context) { c.YAML(http.StatusOK, H{"name": "gee"}) })
+	r.GET("/protobuf", func(c *Context) { c.ProtoBuf(http.StatusOK, wrapperspb.String("gee")) })
+	r.GET("/negotiate", func(c *Context) {
+		c.Negotiate(http.StatusOK, Negotiate{
+			Offered: []string{MIMEJSON, MIMEXML, MIMEYAML},
+			Data:    H{"name": "gee"},
+		})
+	})
+	return r
+}
+
+func TestRenderers(t *testing.T) {
+	r := newRenderEngine()
+	cases := []struct {
+		path        string
+		contentType string
+		body        string
+	}{
+		{"/json", "application/json; charset=utf-8", `{"name":"gee"}`},
+		{"/indented", "application/json; charset=utf-8", "{\n    \"name\": \"gee\"\n}"},
+		{"/secure", "application/json; charset=utf-8", `while(1);["gee"]`},
+		{"/jsonp?callback=cb", "application/javascript; charset=utf-8", `cb({"name":"gee"});`},
+		{"/jsonp?callback=jQuery.cb_1", "application/javascript; charset=utf-8", `jQuery.cb_1({"name":"gee"});`},
+		{"/jsonp", "application/json; charset=utf-8", `{"name":"gee"}`},
+		{"/xml", "application/xml; charset=utf-8", `<map><name>gee</name></map>`},
+		{"/yaml", "application/x-yaml; charset=utf-8", "name: gee\n"},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", tc.path, nil))
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != tc.contentType || w.Body.String() != tc.body {
+			t.Fatalf("%s: unexpected response %d %q %q", tc.path, w.Code, w.Header().Get("Content-Type"), w.Body.String())
+		}
+	}
+
+	// callback不能注入脚本
+	for _, callback := range []string{"alert(document.domain)%2F%2F", "cb%3Balert(1)", "1cb"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/jsonp?callback="+callback, nil))
+		if w.Code != http.StatusBadRequest || w.Body.String() != `{"message":"gee: invalid JSONP callback"}` {
+			t.Fatalf("%s: unexpected response %d %q", callback, w.Code, w.Body.String())
+		}
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/jsonp?callback=cb", nil))
+	if w.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Fatalf("JSONP should set nosniff, got %v", w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/protobuf", nil))
+	msg := &wrapperspb.StringValue{}
+	if err := proto.Unmarshal(w.Body.Bytes(), msg); err != nil || msg.Value != "gee" {
+		t.Fatalf("unexpected protobuf response %v %v", msg, err)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	r := newRenderEngine()
+	cases := map[string]string{
+		"":                                    "application/json; charset=utf-8",
+		"application/xml":                     "application/xml; charset=utf-8",
+		"text/html, application/x-yaml;q=0.9": "application/x-yaml; charset=utf-8",
+		"application/json;q=0.5, application/x-yaml": "application/x-yaml; charset=utf-8",
+		"application/xml;q=0, application/json":      "application/json; charset=utf-8",
+	}
+	for accept, contentType := range cases {
+		req := httptest.NewRequest("GET", "/negotiate", nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Header().Get("Content-Type") != contentType {
+			t.Fatalf("Accept %q: Content-Type should be %q, got %q", accept, contentType, w.Header().Get("Content-Type"))
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/negotiate", nil)
+	req.Header.Set("Accept", "image/png")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotAcceptable {
+		t.Fatalf("status should be 406, got %d", w.Code)
+	}
+}
@@ -0,0 +1,126 @@
+package gee
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+)
+
+const (
+	noWritten     = -1
+	defaultStatus = http.StatusOK
+)
+
+// ResponseWriter 包装了http.ResponseWriter，记录状态码、写入的字节数以及响应头是否已经发送
+// 中间件可以据此判断能不能再修改响应
+type ResponseWriter interface {
+	http.ResponseWriter
+	http.Flusher
+	http.Hijacker
+	http.Pusher
+
+	// Status 返回响应的状态码，还没有设置时为200
+	Status() int
+	// Size 返回已经写入响应体的字节数，响应头还没有发送时为-1
+	Size() int
+	// Written 返回响应头是否已经发送
+	Written() bool
+	// WriteHeaderNow 立即发送响应头
+	WriteHeaderNow()
+	// WriteString 写入字符串
+	WriteString(s string) (int, error)
+}
+
+/*
+	WriteHeader 只记录状态码，直到第一次Write或者请求处理完时才真正发送响应头，
+	所以在写入响应体之前都可以修改状态码
+*/
+type responseWriter struct {
+	http.ResponseWriter
+	size   int
+	status int
+}
+
+var _ ResponseWriter = &responseWriter{}
+
+func (w *responseWriter) reset(writer http.ResponseWriter) {
+	w.ResponseWriter = writer
+	w.size = noWritten
+	w.status = defaultStatus
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if code > 0 && w.status != code {
+		if w.Written() {
+			if IsDebugging() {
+				log.Printf("[WARNING] Headers were already written. Wanted to override status code %d with %d", w.status, code)
+			}
+			return
+		}
+		w.status = code
+	}
+}
+
+func (w *responseWriter) WriteHeaderNow() {
+	if !w.Written() {
+		w.size = 0
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *responseWriter) Write(data []byte) (n int, err error) {
+	w.WriteHeaderNow()
+	n, err = w.ResponseWriter.Write(data)
+	w.size += n
+	return
+}
+
+func (w *responseWriter) WriteString(s string) (n int, err error) {
+	w.WriteHeaderNow()
+	n, err = io.WriteString(w.ResponseWriter, s)
+	w.size += n
+	return
+}
+
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+func (w *responseWriter) Written() bool {
+	return w.size != noWritten
+}
+
+// Flush 发送响应头和缓冲的数据，底层不支持时什么都不做
+func (w *responseWriter) Flush() {
+	w.WriteHeaderNow()
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack 接管底层连接，例如websocket，之后不能再通过ResponseWriter写入
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("gee: the ResponseWriter doesn't support hijacking")
+	}
+	if w.size < 0 {
+		w.size = 0
+	}
+	return hijacker.Hijack()
+}
+
+// Push HTTP/2的服务端推送，底层不支持时返回http.ErrNotSupported
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := w.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
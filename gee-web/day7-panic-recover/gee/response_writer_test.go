@@ -0,0 +1,97 @@
+package gee
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestResponseWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := &responseWriter{}
+	w.reset(rec)
+	if w.Written() || w.Size() != -1 || w.Status() != http.StatusOK {
+		t.Fatal("a new writer should not be written")
+	}
+
+	// 写入响应体之前可以修改状态码
+	w.WriteHeader(http.StatusNotFound)
+	w.WriteHeader(http.StatusAccepted)
+	if rec.Code != http.StatusOK || rec.Flushed {
+		t.Fatal("WriteHeader should not send the header")
+	}
+	w.WriteString("hello")
+	w.Write([]byte(" gee"))
+	if !w.Written() || w.Size() != 9 || rec.Code != http.StatusAccepted {
+		t.Fatalf("unexpected writer state %d %d", w.Size(), rec.Code)
+	}
+
+	// 响应头已经发送，状态码不能再修改
+	w.WriteHeader(http.StatusInternalServerError)
+	if w.Status() != http.StatusAccepted {
+		t.Fatalf("status should stay 202, got %d", w.Status())
+	}
+
+	w.Flush()
+	if !rec.Flushed {
+		t.Fatal("Flush should pass through to the underlying writer")
+	}
+	if err := w.Push("/static/gee.css", nil); err != http.ErrNotSupported {
+		t.Fatalf("Push should return ErrNotSupported, got %v", err)
+	}
+}
+
+func TestFailAfterWrite(t *testing.T) {
+	r := New()
+	var status int
+	r.Use(func(c *Context) {
+		c.Next()
+		status = c.Writer.Status()
+	})
+	r.GET("/partial", func(c *Context) {
+		c.Writer.Write([]byte("partial"))
+		c.Fail(http.StatusInternalServerError, "failed")
+	})
+	r.GET("/status", func(c *Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/partial", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "partial" || status != http.StatusOK {
+		t.Fatalf("Fail after a partial response shouldn't write again, got %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+	if w.Code != http.StatusNoContent || status != http.StatusNoContent {
+		t.Fatalf("status should be 204, got %d %d", w.Code, status)
+	}
+}
+
+func TestRewriteHeaderWarning(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	defer SetMode(Mode())
+
+	w := &responseWriter{}
+	w.reset(httptest.NewRecorder())
+	w.WriteString("gee")
+
+	// 只有debug模式才提示重复设置状态码
+	SetMode(ReleaseMode)
+	w.WriteHeader(http.StatusNotFound)
+	if buf.Len() != 0 {
+		t.Fatalf("warning shouldn't be printed in release mode, got %q", buf.String())
+	}
+	SetMode(DebugMode)
+	w.WriteHeader(http.StatusNotFound)
+	if !strings.Contains(buf.String(), "[WARNING]") {
+		t.Fatalf("warning should be printed in debug mode, got %q", buf.String())
+	}
+}
@@ -0,0 +1,87 @@
+package gee
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Route 是注册的一条路由，GET、POST等方法返回它，可以用Name起名字
+type Route struct {
+	Method  string // Any注册的路由为ANY
+	Pattern string // 带上分组前缀的完整pattern，例如 /v1/p/:lang/doc
+	engine  *Engine
+}
+
+/*
+	Name 给路由起名字，之后可以用Engine.URL或者模板中的url函数生成路径，例如
+	r.GET("/p/:lang/doc", handler).Name("doc")
+	r.URL("doc", "lang", "go") 返回 /p/go/doc
+	名字重复时panic
+*/
+func (r *Route) Name(name string) *Route {
+	engine := r.engine
+	if _, ok := engine.namedRoutes[name]; ok {
+		panic(fmt.Sprintf("gee: route name '%s' is already used", name))
+	}
+	if engine.namedRoutes == nil {
+		engine.namedRoutes = make(map[string]*Route)
+	}
+	engine.namedRoutes[name] = r
+	return r
+}
+
+/*
+	URL 按路由名字生成路径，pairs是参数名和值交替的列表，例如
+	r.URL("static", "filepath", "css/geektutu.css") 对于 /assets/*filepath 返回 /assets/css/geektutu.css
+	:param的值整体转义，*catchall的值按/分段转义，保留其中的/。
+	路由不存在、缺少:param的值、值不满足约束或者多传了参数时返回错误
+*/
+func (engine *Engine) URL(name string, pairs ...string) (string, error) {
+	route, ok := engine.namedRoutes[name]
+	if !ok {
+		return "", fmt.Errorf("gee: no route named '%s'", name)
+	}
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("gee: route '%s': params must be key/value pairs", name)
+	}
+	values := make(map[string]string, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		values[pairs[i]] = pairs[i+1]
+	}
+
+	parts := strings.Split(route.Pattern, "/")
+loop:
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		switch part[0] {
+		case ':':
+			key, constraint := parseParam(part, route.Pattern)
+			value := values[key]
+			if value == "" {
+				return "", fmt.Errorf("gee: route '%s': missing value for param '%s'", name, key)
+			}
+			if constraint != nil && !constraint.match(value) {
+				return "", fmt.Errorf("gee: route '%s': value '%s' of param '%s' does not match <%s>", name, value, key, constraint)
+			}
+			delete(values, key)
+			parts[i] = url.PathEscape(value)
+		case '*':
+			value := values[part[1:]]
+			delete(values, part[1:])
+			segments := strings.Split(strings.TrimPrefix(value, "/"), "/")
+			for j := range segments {
+				segments[j] = url.PathEscape(segments[j])
+			}
+			// *之后的部分在注册时已经被忽略了
+			parts = append(parts[:i], strings.Join(segments, "/"))
+			break loop
+		}
+	}
+	for key := range values {
+		return "", fmt.Errorf("gee: route '%s' (%s) has no param '%s'", name, route.Pattern, key)
+	}
+	return strings.Join(parts, "/"), nil
+}
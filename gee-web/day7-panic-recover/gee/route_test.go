@@ -0,0 +1,75 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRouteURL(t *testing.T) {
+	r := New()
+	h := func(c *Context) {}
+	r.GET("/p/:lang/doc", h).Name("doc")
+	v1 := r.Group("/v1")
+	v1.GET("/users/:id/", h).Name("user")
+	v1.Any("/assets/*filepath", h).Name("assets")
+	r.GET("/", h).Name("home")
+	r.GET("/posts/:id<int>", h).Name("post")
+
+	cases := []struct {
+		name  string
+		pairs []string
+		url   string
+	}{
+		{"doc", []string{"lang", "go"}, "/p/go/doc"},
+		{"doc", []string{"lang", "c/c++ 中文"}, "/p/c%2Fc++%20%E4%B8%AD%E6%96%87/doc"},
+		{"user", []string{"id", "1"}, "/v1/users/1/"},
+		{"assets", []string{"filepath", "/css/a b.css"}, "/v1/assets/css/a%20b.css"},
+		{"assets", nil, "/v1/assets/"},
+		{"home", nil, "/"},
+		{"post", []string{"id", "42"}, "/posts/42"},
+	}
+	for _, tc := range cases {
+		url, err := r.URL(tc.name, tc.pairs...)
+		if err != nil || url != tc.url {
+			t.Fatalf("URL(%q, %v) should be %q, got %q %v", tc.name, tc.pairs, tc.url, url, err)
+		}
+	}
+
+	errCases := [][]string{
+		{"none"},
+		{"doc"},
+		{"doc", "lang"},
+		{"doc", "lang", "go", "id", "1"},
+		{"post", "id", "abc"},
+	}
+	for _, args := range errCases {
+		if url, err := r.URL(args[0], args[1:]...); err == nil {
+			t.Fatalf("URL%v should fail, got %q", args, url)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("duplicate route name should panic")
+		}
+	}()
+	r.GET("/hello", h).Name("doc")
+}
+
+func TestRouteURLTemplateFunc(t *testing.T) {
+	r := New()
+	r.GET("/p/:lang/doc", func(c *Context) {
+		c.HTML(http.StatusOK, "link.tmpl", c.Param("lang"))
+	}).Name("doc")
+	r.LoadHTMLFS(fstest.MapFS{
+		"link.tmpl": {Data: []byte(`<a href="{{url "doc" "lang" .}}">doc</a>`)},
+	}, "*.tmpl")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/p/go/doc", nil))
+	if w.Body.String() != `<a href="/p/go/doc">doc</a>` {
+		t.Fatalf("unexpected body %q", w.Body.String())
+	}
+}
@@ -3,6 +3,9 @@ package gee
 import (
 	"log"
 	"net/http"
+	"net/url"
+	"path"
+	"sort"
 	"strings"
 )
 
@@ -13,18 +16,42 @@ import (
 	router 的 handle 方法作了一个细微的调整，即 handler 的参数，变成了 Context。
 */
 type router struct {
-	roots    map[string]*node       // 存储每种请求方式的Trie 树根节点
-	handlers map[string]HandlerFunc // 存储每种请求方式的 HandlerFunc
+	roots     map[string]*node // 存储每种请求方式的前缀树根节点，调用链保存在节点上
+	maxParams int              // 所有路由中参数最多的个数，用来分配Params的容量
+	host      *hostRouter      // Host分组的路由，默认的路由为nil
 }
 
 // roots key eg, roots['GET'] roots['POST']
-// handlers key eg, handlers['GET-/p/:lang/doc'], handlers['POST-/p/book']
 // router的构造函数
 func newRouter() *router {
-	return &router{
-		roots:    make(map[string]*node),
-		handlers: make(map[string]HandlerFunc),
+	return &router{roots: make(map[string]*node)}
+}
+
+// Param 是一个路由参数，由key和value组成
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Params 按路由中出现的顺序保存参数，一个路由的参数很少，线性查找比map更快，也不需要分配内存
+// 例如 /p/go/doc 匹配到 /p/:lang/doc，解析结果为：[{lang go}]，
+// /static/css/geektutu.css 匹配到 /static/*filepath ，解析结果为 [{filepath css/geektutu.css}]。
+type Params []Param
+
+// Get 返回第一个名字为name的参数值，以及这个参数是否存在
+func (ps Params) Get(name string) (string, bool) {
+	for _, p := range ps {
+		if p.Key == name {
+			return p.Value, true
+		}
 	}
+	return "", false
+}
+
+// ByName 返回名字为name的参数值，不存在时返回空字符串
+func (ps Params) ByName(name string) string {
+	value, _ := ps.Get(name)
+	return value
 }
 
 // 根据传入的待匹配的pattern，解析成string数组
@@ -44,54 +71,69 @@ func parsePattern(pattern string) []string {
 	return parts
 }
 
-// 增加路由规则
-func (r *router) addRoute(method string, pattern string, handler HandlerFunc) {
+// 替换已经注册的路由的调用链
+func (r *router) setHandlers(method string, pattern string, handlers []HandlerFunc) {
+	root, ok := r.roots[method]
+	if !ok {
+		return
+	}
+	nodes := make([]*node, 0)
+	root.travel(&nodes)
+	for _, n := range nodes {
+		if n.pattern == pattern {
+			n.handlers = handlers
+			return
+		}
+	}
+}
 
-	parts := parsePattern(pattern)
+// 增加路由规则
+// handlers 是包含分组中间件在内的完整调用链
+func (r *router) addRoute(method string, pattern string, handlers []HandlerFunc) {
+	segments := parseSegments(pattern)
 
-	log.Printf("Route %4s - %4s", method, pattern)
-	key := method + "-" + pattern
+	if r.host != nil {
+		log.Printf("Route %4s - %s%s", method, r.host.pattern, pattern)
+	} else {
+		log.Printf("Route %4s - %4s", method, pattern)
+	}
 
 	_, ok := r.roots[method]
 	if !ok {
 		r.roots[method] = &node{} // 插入一个根节点
 	}
-	r.roots[method].insert(pattern, parts, 0)
+	r.roots[method].insert(pattern, segments, handlers)
 
-	r.handlers[key] = handler
+	nParams := 0
+	for _, seg := range segments {
+		if seg.nType != static {
+			nParams++
+		}
+	}
+	if nParams > r.maxParams {
+		r.maxParams = nParams
+	}
 }
 
-// 获取路由，参数为请求方法和请求路径
-func (r *router) getRoute(method string, path string) (*node, map[string]string) {
-	searchParts := parsePattern(path) // 实际的路径解析的结果
-	params := make(map[string]string) // 动态路径参数，只在当前查询内有效，有可能一次请求路径没有动态参数
-
+// 查找路由，匹配到的参数追加到params中，params容量足够时不分配内存
+func (r *router) lookup(method string, path string, params *Params) *node {
 	root, ok := r.roots[method]
 	if !ok {
-		return nil, nil
-	}
-	// 只有trie树上有这条路径，n才不为空,这个n有pattern的字段
-	n := root.search(searchParts, 0)
-	if n != nil {
-		// trie树上的解析结果
-		// n的pattern可能和path不一样
-		parts := parsePattern(n.pattern)
-		// 例如
-		// /p/go/doc 匹配到 /p/:lang/doc，解析结果为：{lang: "go"}，
-		// /static/css/geektutu.css 匹配到 /static/*filepath ，解析结果为 {filepath: "css/geektutu.css"}。
-		for index, part := range parts {
-			if part[0] == ':' {
-				params[part[1:]] = searchParts[index]
-			}
-			if part[0] == '*' && len(part) > 1 {
-				params[part[1:]] = strings.Join(searchParts[index:], "/")
-				break
-			}
-		}
-		return n, params
+		return nil
 	}
+	return root.search(path, params)
+}
 
-	return nil, nil
+// 获取路由，参数为请求方法和请求路径
+// 返回的Params是新分配的，处理请求时用lookup和Context里复用的Params
+func (r *router) getRoute(method string, path string) (*node, Params) {
+	params := make(Params, 0, r.maxParams)
+	// 只有前缀树上有这条路径，n才不为空,这个n有pattern的字段
+	n := r.lookup(method, path, &params)
+	if n == nil {
+		return nil, nil
+	}
+	return n, params
 }
 
 func (r *router) getRoutes(method string) []*node {
@@ -104,19 +146,188 @@ func (r *router) getRoutes(method string) []*node {
 	return nodes
 }
 
+// allowedMethods 返回path在其它请求方法的Trie树上能匹配到的方法，用于Allow头
+// 按字母序排列，请求为OPTIONS时会列出全部方法
+func (r *router) allowedMethods(c *Context, path string) string {
+	engine := c.engine
+	mark := len(*c.params)
+	methods := make([]string, 0, len(r.roots))
+	for method := range r.roots {
+		if method == c.Method && c.Method != http.MethodOptions {
+			continue
+		}
+		if r.lookup(method, path, c.params) != nil {
+			*c.params = (*c.params)[:mark]
+			methods = append(methods, method)
+		}
+	}
+	if len(methods) == 0 {
+		return ""
+	}
+	hasMethod := func(method string) bool {
+		for _, m := range methods {
+			if m == method {
+				return true
+			}
+		}
+		return false
+	}
+	if engine.HandleHEAD && hasMethod(http.MethodGet) && !hasMethod(http.MethodHead) {
+		methods = append(methods, http.MethodHead)
+	}
+	if engine.HandleOPTIONS && !hasMethod(http.MethodOptions) {
+		methods = append(methods, http.MethodOptions)
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
 // handle函数执行路由的跳转，不同的key对应不同的路由规则，这里的handler是一个函数
+// c.params中可能已经有Host匹配到的参数，路径上的参数追加在后面
 func (r *router) handle(c *Context) {
-	n, params := r.getRoute(c.Method, c.Path)
-	//log.Println(params)
-	if n != nil {
-		c.Params = params
-		key := c.Method + "-" + n.pattern
-		c.handlers = append(c.handlers, r.handlers[key])
+	engine := c.engine
+	base := len(*c.params)
+	path := c.Path
+	raw, unescape := false, false
+	// RawPath保留了原始的转义，例如 /p/a%2Fb/doc 中的 %2F 不会被当成 /
+	if engine.UseRawPath && c.Req.URL.RawPath != "" {
+		path = c.Req.URL.RawPath
+		raw, unescape = true, engine.UnescapePathValues
+	}
 
-	} else {
-		c.handlers = append(c.handlers, func(c *Context) {
-			c.String(http.StatusNotFound, "404 NOT FOUND: %s\n", c.Path)
-		})
+	if n := r.find(c, c.Method, path); n != nil {
+		if unescape {
+			params := (*c.params)[base:]
+			for i := range params {
+				if v, err := url.PathUnescape(params[i].Value); err == nil {
+					params[i].Value = v
+				}
+			}
+		}
+		c.Params = *c.params
+		c.fullPath = n.pattern
+		c.handlers = n.handlers
+		c.Next()
+		return
+	}
+
+	// 路径只差结尾的/，或者大小写、多余的/和..不同时，重定向到规范的路径
+	if c.Method != http.MethodConnect && path != "/" {
+		if engine.RedirectTrailingSlash {
+			alt := path + "/"
+			if strings.HasSuffix(path, "/") {
+				alt = path[:len(path)-1]
+			}
+			if r.find(c, c.Method, alt) != nil {
+				*c.params = (*c.params)[:base]
+				r.redirect(c, alt, raw)
+				return
+			}
+		}
+		if engine.RedirectFixedPath {
+			if fixed, ok := r.findCaseInsensitive(c, cleanPath(path)); ok {
+				r.redirect(c, fixed, raw)
+				return
+			}
+		}
+	}
+
+	// 没有匹配到路由时只保留Host的参数
+	c.Params = (*c.params)[:base]
+
+	// 路径在其它方法的Trie树上存在，自动回复OPTIONS或者返回405
+	if engine.HandleOPTIONS || engine.HandleMethodNotAllowed {
+		if allow := r.allowedMethods(c, path); allow != "" {
+			if c.Method == http.MethodOptions && engine.HandleOPTIONS {
+				c.handlers = engine.missHandlers(r, c.Path, func(c *Context) {
+					c.SetHeader("Allow", allow)
+					c.Status(http.StatusNoContent)
+				})
+				c.Next()
+				return
+			}
+			if engine.HandleMethodNotAllowed {
+				c.SetHeader("Allow", allow)
+				c.Status(http.StatusMethodNotAllowed)
+				c.handlers = engine.missHandlers(r, c.Path, engine.noMethod...)
+				c.Next()
+				return
+			}
+		}
+	}
+
+	c.Status(http.StatusNotFound)
+	c.handlers = engine.missHandlers(r, c.Path, engine.noRoute...)
+	c.Next()
+}
+
+// 按请求方法查找路由，HEAD请求没有注册时，借用GET的handler，net/http 会丢弃HEAD响应的body
+func (r *router) find(c *Context, method string, path string) *node {
+	n := r.lookup(method, path, c.params)
+	if n == nil && method == http.MethodHead && c.engine.HandleHEAD {
+		n = r.lookup(http.MethodGet, path, c.params)
+	}
+	return n
+}
+
+// 不区分大小写地查找路由，返回树中注册的写法，RedirectTrailingSlash时顺便修正结尾的/
+func (r *router) findCaseInsensitive(c *Context, path string) (string, bool) {
+	methods := []string{c.Method}
+	if c.Method == http.MethodHead && c.engine.HandleHEAD {
+		methods = append(methods, http.MethodGet)
+	}
+	for _, method := range methods {
+		root, ok := r.roots[method]
+		if !ok {
+			continue
+		}
+		buf := root.findCaseInsensitive(path, make([]byte, 0, len(path)+1), c.engine.RedirectTrailingSlash)
+		if buf != nil {
+			return string(buf), true
+		}
+	}
+	return "", false
+}
+
+/*
+	重定向到规范的路径，保留query参数
+	GET和HEAD返回301，其它方法返回308，浏览器会用原来的方法和body重新请求
+	location是解码后的路径时要重新转义，否则 %3F 会变成query，%09 会被浏览器丢掉；escaped表示已经是RawPath
+*/
+func (r *router) redirect(c *Context, location string, escaped bool) {
+	code := http.StatusMovedPermanently
+	if c.Method != http.MethodGet && c.Method != http.MethodHead {
+		code = http.StatusPermanentRedirect
+	}
+	// //evil.com 和 /\evil.com 都会被浏览器当成另一个域名，开头连续的/和\只保留一个/
+	location = "/" + strings.TrimLeft(location, "/\\")
+	if !escaped {
+		location = (&url.URL{Path: location}).EscapedPath()
 	}
+	if c.Req.URL.RawQuery != "" {
+		location += "?" + c.Req.URL.RawQuery
+	}
+	c.handlers = c.engine.missHandlers(r, c.Path, func(c *Context) {
+		c.SetHeader("Location", location)
+		c.Status(code)
+	})
 	c.Next()
 }
+
+/*
+	cleanPath 返回规范的路径：以/开头，去掉多余的/、.和..，保留结尾的/
+	例如 //hello/../p/./go/ 返回 /p/go/
+*/
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	np := path.Clean(p)
+	if p[len(p)-1] == '/' && np != "/" {
+		np += "/"
+	}
+	return np
+}
@@ -2,6 +2,8 @@ package gee
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 )
@@ -38,24 +40,24 @@ func TestGetRoute(t *testing.T) {
 		t.Fatal("should match /hello/:name")
 	}
 
-	if ps["name"] != "geektutu" {
+	if ps.ByName("name") != "geektutu" {
 		t.Fatal("name should be equal to 'geektutu'")
 	}
 
-	fmt.Printf("matched path: %s, params['name']: %s\n", n.pattern, ps["name"])
+	fmt.Printf("matched path: %s, params['name']: %s\n", n.pattern, ps.ByName("name"))
 
 }
 
 func TestGetRoute2(t *testing.T) {
 	r := newTestRouter()
 	n1, ps1 := r.getRoute("GET", "/assets/file1.txt")
-	ok1 := n1.pattern == "/assets/*filepath" && ps1["filepath"] == "file1.txt"
+	ok1 := n1.pattern == "/assets/*filepath" && ps1.ByName("filepath") == "file1.txt"
 	if !ok1 {
 		t.Fatal("pattern shoule be /assets/*filepath & filepath shoule be file1.txt")
 	}
 
 	n2, ps2 := r.getRoute("GET", "/assets/css/test.css")
-	ok2 := n2.pattern == "/assets/*filepath" && ps2["filepath"] == "css/test.css"
+	ok2 := n2.pattern == "/assets/*filepath" && ps2.ByName("filepath") == "css/test.css"
 	if !ok2 {
 		t.Fatal("pattern shoule be /assets/*filepath & filepath shoule be css/test.css")
 	}
@@ -73,3 +75,432 @@ func TestGetRoutes(t *testing.T) {
 		t.Fatal("the number of routes shoule be 4")
 	}
 }
+
+func TestMethodNotAllowed(t *testing.T) {
+	r := New()
+	r.GET("/hello", func(c *Context) { c.String(http.StatusOK, "hello") })
+	r.PUT("/hello", func(c *Context) { c.String(http.StatusOK, "put") })
+
+	// 默认关闭，和以前一样返回404
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/hello", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status should be 404, got %d", w.Code)
+	}
+
+	r.HandleMethodNotAllowed = true
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/hello", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("status should be 405, got %d", w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "GET, PUT" {
+		t.Fatalf("unexpected Allow header %q", allow)
+	}
+}
+
+func TestAutoOptionsAndHead(t *testing.T) {
+	r := New()
+	r.GET("/hello", func(c *Context) { c.String(http.StatusOK, "hello") })
+
+	// 默认关闭，和以前一样返回404
+	for _, method := range []string{http.MethodOptions, http.MethodHead} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, "/hello", nil))
+		if w.Code != http.StatusNotFound || w.Header().Get("Allow") != "" {
+			t.Fatalf("%s should return 404 by default, got %d %q", method, w.Code, w.Header().Get("Allow"))
+		}
+	}
+
+	r.HandleOPTIONS = true
+	r.HandleHEAD = true
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/hello", nil))
+	if w.Code != http.StatusNoContent || w.Header().Get("Allow") != "GET, HEAD, OPTIONS" {
+		t.Fatalf("unexpected OPTIONS response %d %q", w.Code, w.Header().Get("Allow"))
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/hello", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("HEAD should fall back to GET, got %d", w.Code)
+	}
+}
+
+func TestAnyMethod(t *testing.T) {
+	r := New()
+	r.Any("/any", func(c *Context) { c.String(http.StatusOK, c.Method) })
+	for _, method := range []string{http.MethodGet, http.MethodDelete, http.MethodPatch} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, "/any", nil))
+		if w.Code != http.StatusOK || w.Body.String() != method {
+			t.Fatalf("%s /any: got %d %q", method, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestRoutePriority(t *testing.T) {
+	// 不管注册顺序如何，都应该是静态节点 > :参数 > *通配
+	orders := [][]string{
+		{"/hello/:name", "/hello/b/c", "/hello/*any", "/hello/b"},
+		{"/hello/*any", "/hello/b", "/hello/b/c", "/hello/:name"},
+	}
+	for _, patterns := range orders {
+		r := newRouter()
+		for _, pattern := range patterns {
+			r.addRoute("GET", pattern, nil)
+		}
+		cases := map[string]string{
+			"/hello/b":      "/hello/b",
+			"/hello/b/c":    "/hello/b/c",
+			"/hello/geek":   "/hello/:name",
+			"/hello/b/d":    "/hello/*any",
+			"/hello/geek/c": "/hello/*any",
+		}
+		for path, want := range cases {
+			n, _ := r.getRoute("GET", path)
+			if n == nil || n.pattern != want {
+				t.Fatalf("registered %v: %s should match %s, got %v", patterns, path, want, n)
+			}
+		}
+	}
+}
+
+func TestRouteConflict(t *testing.T) {
+	conflicts := [][]string{
+		{"/hello/:name", "/hello/:id"},
+		{"/assets/*filepath", "/assets/*file"},
+		{"/hello/:name", "/hello/:name"},
+	}
+	for _, patterns := range conflicts {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("registering %v should panic", patterns)
+				}
+			}()
+			r := newRouter()
+			for _, pattern := range patterns {
+				r.addRoute("GET", pattern, nil)
+			}
+		}()
+	}
+}
+
+// 基数树按完整的路径匹配，结尾多一个/的是不同的路由，不再冲突；
+// *通配可以匹配空值，例如 /assets/ 匹配 /assets/*filepath，filepath为空
+func TestTrailingSlashRoutes(t *testing.T) {
+	r := newRouter()
+	for _, pattern := range []string{"/p/:lang/doc", "/p/:lang/doc/", "/assets/*filepath"} {
+		r.addRoute("GET", pattern, nil)
+	}
+	cases := []struct {
+		path    string
+		pattern string
+		params  Params
+	}{
+		{"/p/go/doc", "/p/:lang/doc", Params{{"lang", "go"}}},
+		{"/p/go/doc/", "/p/:lang/doc/", Params{{"lang", "go"}}},
+		{"/assets/", "/assets/*filepath", Params{{"filepath", ""}}},
+		{"/assets/css/gee.css", "/assets/*filepath", Params{{"filepath", "css/gee.css"}}},
+	}
+	for _, tc := range cases {
+		n, ps := r.getRoute("GET", tc.path)
+		if n == nil || n.pattern != tc.pattern {
+			t.Fatalf("%s should match %s, got %v", tc.path, tc.pattern, n)
+		}
+		if !reflect.DeepEqual(ps, tc.params) {
+			t.Fatalf("%s: params should be %v, got %v", tc.path, tc.params, ps)
+		}
+	}
+	if n, _ := r.getRoute("GET", "/assets"); n != nil {
+		t.Fatalf("/assets shouldn't match, got %s", n.pattern)
+	}
+}
+
+func TestRadixTree(t *testing.T) {
+	r := newRouter()
+	patterns := []string{
+		"/", "/he", "/hello", "/help", "/hello/", "/hi/:name/:action",
+		"/hi/:name/profile", "/src/*filepath", "/src/static/main.css",
+	}
+	for _, pattern := range patterns {
+		r.addRoute("GET", pattern, nil)
+	}
+	cases := []struct {
+		path    string
+		pattern string
+		params  Params
+	}{
+		{"/", "/", Params{}},
+		{"/he", "/he", Params{}},
+		{"/hello", "/hello", Params{}},
+		{"/hello/", "/hello/", Params{}},
+		{"/help", "/help", Params{}},
+		{"/hel", "", nil},
+		{"/hi/geek/profile", "/hi/:name/profile", Params{{"name", "geek"}}},
+		{"/hi/geek/edit", "/hi/:name/:action", Params{{"name", "geek"}, {"action", "edit"}}},
+		{"/hi//edit", "", nil},
+		{"/src/", "/src/*filepath", Params{{"filepath", ""}}},
+		{"/src/static/main.css", "/src/static/main.css", Params{}},
+		{"/src/static/app.js", "/src/*filepath", Params{{"filepath", "static/app.js"}}},
+	}
+	for _, tc := range cases {
+		n, ps := r.getRoute("GET", tc.path)
+		if tc.pattern == "" {
+			if n != nil {
+				t.Fatalf("%s shouldn't match, got %s", tc.path, n.pattern)
+			}
+			continue
+		}
+		if n == nil || n.pattern != tc.pattern {
+			t.Fatalf("%s should match %s, got %v", tc.path, tc.pattern, n)
+		}
+		if !reflect.DeepEqual(ps, tc.params) {
+			t.Fatalf("%s: params should be %v, got %v", tc.path, tc.params, ps)
+		}
+	}
+}
+
+func TestRedirectTrailingSlash(t *testing.T) {
+	r := New()
+	r.HandleHEAD = true // HEAD /users/1 使用GET的路由重定向
+	h := func(c *Context) { c.String(http.StatusOK, c.FullPath()) }
+	r.GET("/hello", h)
+	r.GET("/users/:id/", h)
+	r.POST("/users", h)
+
+	cases := []struct {
+		method, path string
+		code         int
+		location     string
+	}{
+		{"GET", "/hello/", http.StatusMovedPermanently, "/hello"},
+		{"GET", "/hello/?q=1", http.StatusMovedPermanently, "/hello?q=1"},
+		{"HEAD", "/users/1", http.StatusMovedPermanently, "/users/1/"},
+		{"POST", "/users/", http.StatusPermanentRedirect, "/users"},
+		{"GET", "/hello", http.StatusOK, ""},
+		{"GET", "/", http.StatusNotFound, ""},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		if w.Code != tc.code || w.Header().Get("Location") != tc.location {
+			t.Fatalf("%s %s: unexpected response %d %q", tc.method, tc.path, w.Code, w.Header().Get("Location"))
+		}
+	}
+
+	// 重定向不能跳到其它域名
+	r.GET("/:name", h)
+	r.GET("/:name/:page", h)
+	redirects := map[string]string{
+		"/%5Cevil.com/":  "/evil.com",
+		"/\\/evil.com/":  "/evil.com",
+		"/%09/evil.com/": "/%09/evil.com",
+		// 转义的字符重定向后仍然是路径的一部分
+		"/a%3Fb/c/": "/a%3Fb/c",
+	}
+	for path, location := range redirects {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != location {
+			t.Fatalf("%s: unexpected response %d %q", path, w.Code, w.Header().Get("Location"))
+		}
+	}
+
+	r.RedirectTrailingSlash = false
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/hello/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status should be 404, got %d", w.Code)
+	}
+}
+
+func TestRedirectFixedPath(t *testing.T) {
+	r := New()
+	r.RedirectFixedPath = true
+	h := func(c *Context) {}
+	r.GET("/hello/world", h)
+	r.GET("/Users/:name/Repos", h)
+	r.GET("/docs/", h)
+	r.GET("/assets/*filepath", h)
+
+	cases := map[string]string{
+		"/HELLO/World":          "/hello/world",
+		"//hello/./x/../world":  "/hello/world",
+		"/users/GeekTutu/repos": "/Users/GeekTutu/Repos",
+		"/users/a%3Fb/repos":    "/Users/a%3Fb/Repos",
+		"/DOCS":                 "/docs/",
+		"/Hello/World/":         "/hello/world",
+		"/ASSETS/Gee.css":       "/assets/Gee.css",
+	}
+	for path, location := range cases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != location {
+			t.Fatalf("%s: unexpected response %d %q", path, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestUseRawPath(t *testing.T) {
+	r := New()
+	r.GET("/p/:lang/doc", func(c *Context) { c.String(http.StatusOK, c.Param("lang")) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/p/c%2Fc++/doc", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("%%2F should be decoded as / without UseRawPath, got %d", w.Code)
+	}
+
+	r.UseRawPath = true
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/p/c%2Fc++/doc", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "c/c++" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+
+	r.UnescapePathValues = false
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/p/c%2Fc++/doc", nil))
+	if w.Body.String() != "c%2Fc++" {
+		t.Fatalf("param should be kept escaped, got %q", w.Body.String())
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	cases := map[string]string{
+		"":                  "/",
+		"hello":             "/hello",
+		"//hello//world/":   "/hello/world/",
+		"/a/./b/../../c":    "/c",
+		"/../..":            "/",
+		"/hello/world/./..": "/hello",
+	}
+	for path, expected := range cases {
+		if cleaned := cleanPath(path); cleaned != expected {
+			t.Fatalf("cleanPath(%q) should be %q, got %q", path, expected, cleaned)
+		}
+	}
+}
+
+func TestParamConstraints(t *testing.T) {
+	r := newRouter()
+	for _, pattern := range []string{
+		"/users/:id<int>",
+		"/users/:name",
+		"/users/:uuid<uuid>/files",
+		"/users/:id<int>/repos",
+		"/posts/:slug<[a-z-]+>",
+		"/posts/:slug<[a-z-]+>/edit",
+		"/posts/:year<[0-9]{4}>/:slug",
+		"/posts/new",
+	} {
+		r.addRoute("GET", pattern, nil)
+	}
+
+	cases := []struct {
+		path    string
+		pattern string
+		params  Params
+	}{
+		{"/users/42", "/users/:id<int>", Params{{"id", "42"}}},
+		{"/users/-7", "/users/:id<int>", Params{{"id", "-7"}}},
+		{"/users/geektutu", "/users/:name", Params{{"name", "geektutu"}}},
+		{"/users/42/repos", "/users/:id<int>/repos", Params{{"id", "42"}}},
+		{"/users/6ba7b810-9dad-11d1-80b4-00c04fd430c8/files", "/users/:uuid<uuid>/files", Params{{"uuid", "6ba7b810-9dad-11d1-80b4-00c04fd430c8"}}},
+		{"/posts/hello-gee", "/posts/:slug<[a-z-]+>", Params{{"slug", "hello-gee"}}},
+		{"/posts/new", "/posts/new", Params{}},
+		{"/posts/2021/hello", "/posts/:year<[0-9]{4}>/:slug", Params{{"year", "2021"}, {"slug", "hello"}}},
+	}
+	for _, tc := range cases {
+		n, ps := r.getRoute("GET", tc.path)
+		if n == nil || n.pattern != tc.pattern || !reflect.DeepEqual(ps, tc.params) {
+			t.Fatalf("%s: unexpected match %v %v", tc.path, n, ps)
+		}
+	}
+	for _, path := range []string{"/users/geektutu/repos", "/users/42/files", "/posts/Hello", "/posts/202/hello"} {
+		if n, _ := r.getRoute("GET", path); n != nil {
+			t.Fatalf("%s should not match %s", path, n.pattern)
+		}
+	}
+}
+
+func TestParamConstraintConflict(t *testing.T) {
+	cases := [][]string{
+		{"/users/:id<int>", "/users/:uid<int>"},
+		{"/users/:id<int", "/users/x"},
+		{"/users/:<int>", "/users/x"},
+		{"/users/:id<[a-z>", "/users/x"},
+	}
+	for _, patterns := range cases {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("%v should panic", patterns)
+				}
+			}()
+			r := newRouter()
+			for _, pattern := range patterns {
+				r.addRoute("GET", pattern, nil)
+			}
+		}()
+	}
+}
+
+var benchRoutes = []string{
+	"/",
+	"/user/:name",
+	"/user/:name/repos",
+	"/user/:name/repos/:repo",
+	"/user/:name/starred",
+	"/repos/:owner/:repo/issues",
+	"/repos/:owner/:repo/issues/:number",
+	"/repos/:owner/:repo/pulls",
+	"/orgs/:org/members",
+	"/search/repositories",
+	"/search/users",
+	"/assets/*filepath",
+}
+
+var benchPaths = []string{
+	"/",
+	"/user/geektutu/repos/gee",
+	"/repos/geektutu/7days-golang/issues/42",
+	"/search/users",
+	"/assets/css/gee.css",
+}
+
+func BenchmarkRadixRouter(b *testing.B) {
+	r := newRouter()
+	for _, pattern := range benchRoutes {
+		r.addRoute("GET", pattern, nil)
+	}
+	ps := make(Params, 0, r.maxParams)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, path := range benchPaths {
+			ps = ps[:0]
+			if r.lookup("GET", path, &ps) == nil {
+				b.Fatalf("%s should be matched", path)
+			}
+		}
+	}
+}
+
+func BenchmarkTrieRouter(b *testing.B) {
+	r := newLegacyRouter()
+	for _, pattern := range benchRoutes {
+		r.addRoute("GET", pattern, func(c *Context) {})
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, path := range benchPaths {
+			if h, _ := r.getRoute("GET", path); h == nil {
+				b.Fatalf("%s should be matched", path)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package gee
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+)
+
+// 按Engine上的配置创建http.Server，并记录下来以便Shutdown
+// Shutdown之后不能再启动新的服务
+func (engine *Engine) newServer(addr string) (*http.Server, error) {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           engine,
+		ReadTimeout:       engine.ReadTimeout,
+		ReadHeaderTimeout: engine.ReadHeaderTimeout,
+		WriteTimeout:      engine.WriteTimeout,
+		IdleTimeout:       engine.IdleTimeout,
+		MaxHeaderBytes:    engine.MaxHeaderBytes,
+	}
+	engine.serversMu.Lock()
+	defer engine.serversMu.Unlock()
+	if engine.shutdown {
+		return nil, http.ErrServerClosed
+	}
+	engine.servers = append(engine.servers, srv)
+	return srv, nil
+}
+
+// Shutdown 之后Run系列方法返回http.ErrServerClosed，对调用者来说是正常退出
+func serveResult(err error) error {
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Run defines the method to start a http server
+// 调用Shutdown之后返回nil
+func (engine *Engine) Run(addr string) (err error) {
+	srv, err := engine.newServer(addr)
+	if err != nil {
+		return serveResult(err)
+	}
+	return serveResult(srv.ListenAndServe())
+}
+
+// RunTLS 启动https服务，certFile和keyFile是证书和私钥文件
+func (engine *Engine) RunTLS(addr string, certFile string, keyFile string) (err error) {
+	srv, err := engine.newServer(addr)
+	if err != nil {
+		return serveResult(err)
+	}
+	return serveResult(srv.ListenAndServeTLS(certFile, keyFile))
+}
+
+// RunUnix 在unix domain socket上启动服务，file是上次留下的socket时会先删除，是其它文件时返回错误
+func (engine *Engine) RunUnix(file string) (err error) {
+	if info, err := os.Lstat(file); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("gee: %s already exists and is not a socket", file)
+		}
+		if err := os.Remove(file); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	listener, err := net.Listen("unix", file)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file)
+	return engine.RunListener(listener)
+}
+
+// RunListener 在已有的listener上启动服务，例如由systemd传入的socket
+func (engine *Engine) RunListener(listener net.Listener) (err error) {
+	srv, err := engine.newServer(listener.Addr().String())
+	if err != nil {
+		listener.Close()
+		return serveResult(err)
+	}
+	return serveResult(srv.Serve(listener))
+}
+
+/*
+	Shutdown 优雅地关闭所有由Run系列方法启动的服务：
+	先停止接受新的连接，再等待处理中的请求完成，ctx超时后直接返回ctx.Err()
+	滚动发布时，收到SIGTERM后调用Shutdown，就不会丢掉正在处理的请求
+*/
+func (engine *Engine) Shutdown(ctx context.Context) error {
+	engine.serversMu.Lock()
+	engine.shutdown = true
+	servers := engine.servers
+	engine.servers = nil
+	engine.serversMu.Unlock()
+
+	var firstErr error
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
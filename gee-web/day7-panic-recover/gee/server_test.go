@@ -0,0 +1,114 @@
+package gee
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestShutdownDrainsRequests(t *testing.T) {
+	r := New()
+	started := make(chan struct{})
+	r.GET("/slow", func(c *Context) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runErr := make(chan error, 1)
+	go func() { runErr <- r.RunListener(listener) }()
+
+	respCh := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/slow")
+		if err != nil {
+			respCh <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		respCh <- string(body)
+	}()
+
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := r.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if body := <-respCh; body != "done" {
+		t.Fatalf("in-flight request should complete, got %q", body)
+	}
+	if err := <-runErr; err != nil {
+		t.Fatalf("RunListener should return nil after Shutdown, got %v", err)
+	}
+	if err := r.Run("127.0.0.1:0"); err != nil {
+		t.Fatalf("Run after Shutdown should return nil, got %v", err)
+	}
+}
+
+func TestRunUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "gee.sock")
+
+	r := New()
+	r.ReadTimeout = time.Second
+	r.GET("/ping", func(c *Context) { c.String(http.StatusOK, "pong") })
+	runErr := make(chan error, 1)
+	go func() { runErr <- r.RunUnix(file) }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", file)
+		},
+	}}
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		if resp, err = client.Get("http://unix/ping"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "pong" {
+		t.Fatalf("unexpected body %q", body)
+	}
+	r.Shutdown(context.Background())
+	if err := <-runErr; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunUnixExistingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "gee.sock")
+	ioutil.WriteFile(file, []byte("data"), 0644)
+
+	// 不是socket的文件不能被删掉
+	if err := New().RunUnix(file); err == nil {
+		t.Fatal("RunUnix should fail when the file is not a socket")
+	}
+	if data, err := ioutil.ReadFile(file); err != nil || string(data) != "data" {
+		t.Fatalf("regular file should be left alone, got %q %v", data, err)
+	}
+}
@@ -0,0 +1,236 @@
+package gee
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StaticConfig 是StaticFS的配置
+type StaticConfig struct {
+	// 允许列出目录中的文件，默认不允许，目录没有Index文件时返回404
+	Browse bool
+	// 请求目录时返回的文件，默认index.html
+	Index string
+	// 单页应用模式，没有扩展名的路径找不到文件时返回根目录的Index，交给前端路由处理
+	SPA bool
+	// 大于0时设置Cache-Control: public, max-age=秒数
+	MaxAge time.Duration
+	// 不生成ETag，默认由修改时间和大小生成，没有修改时间(例如embed.FS)时由内容的哈希生成
+	DisableETag bool
+}
+
+/*
+	StaticFS 把fsys映射到路由relativePath，fsys可以是os.DirFS、embed.FS等任意fs.FS
+	例如
+	//go:embed static
+	var static embed.FS
+	sub, _ := fs.Sub(static, "static")
+	r.StaticFS("/assets", sub, gee.StaticConfig{MaxAge: time.Hour})
+	Range、If-None-Match、If-Modified-Since交给http.ServeContent处理。
+	注册的是GET和HEAD路由，所以会经过分组上的中间件
+*/
+func (group *RouterGroup) StaticFS(relativePath string, fsys fs.FS, conf StaticConfig) {
+	if strings.ContainsAny(relativePath, ":*") {
+		panic("gee: URL parameters can not be used when serving a static folder")
+	}
+	if conf.Index == "" {
+		conf.Index = "index.html"
+	}
+	s := &staticServer{fsys: fsys, conf: conf}
+	handler := func(c *Context) {
+		s.serve(c, c.Param("filepath"))
+	}
+	urlPattern := path.Join(relativePath, "/*filepath")
+	group.GET(urlPattern, handler)
+	group.HEAD(urlPattern, handler)
+}
+
+// StaticFile 把单个本地文件映射到路由relativePath，例如 r.StaticFile("/favicon.ico", "./static/favicon.ico")
+func (group *RouterGroup) StaticFile(relativePath, file string) {
+	if strings.ContainsAny(relativePath, ":*") {
+		panic("gee: URL parameters can not be used when serving a static file")
+	}
+	dir, name := filepath.Split(file)
+	if dir == "" {
+		dir = "."
+	}
+	s := &staticServer{fsys: os.DirFS(dir), conf: StaticConfig{Index: "index.html"}}
+	handler := func(c *Context) {
+		s.serveFile(c, name)
+	}
+	group.GET(relativePath, handler)
+	group.HEAD(relativePath, handler)
+}
+
+type staticServer struct {
+	fsys  fs.FS
+	conf  StaticConfig
+	etags sync.Map // 没有修改时间的文件，按内容生成的ETag
+}
+
+func (s *staticServer) serve(c *Context, filepath string) {
+	// 去掉 .. 等，fs.FS中的路径不能以/开头
+	name := strings.TrimPrefix(path.Clean("/"+filepath), "/")
+	if name == "" {
+		name = "."
+	}
+	if !fs.ValidPath(name) {
+		staticNotFound(c)
+		return
+	}
+	fi, err := fs.Stat(s.fsys, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) && s.conf.SPA && path.Ext(name) == "" {
+			s.serveFile(c, s.conf.Index)
+			return
+		}
+		staticNotFound(c)
+		return
+	}
+	if !fi.IsDir() {
+		s.serveFile(c, name)
+		return
+	}
+
+	// 目录的路径要以/结尾，否则页面中的相对链接会出错
+	if !strings.HasSuffix(c.Req.URL.Path, "/") {
+		target := c.Req.URL.Path + "/"
+		if c.Req.URL.RawQuery != "" {
+			target += "?" + c.Req.URL.RawQuery
+		}
+		c.SetHeader("Location", target)
+		c.AbortWithStatus(http.StatusMovedPermanently)
+		return
+	}
+	index := path.Join(name, s.conf.Index)
+	if fi, err := fs.Stat(s.fsys, index); err == nil && !fi.IsDir() {
+		s.serveFile(c, index)
+		return
+	}
+	if s.conf.Browse {
+		s.listDir(c, name)
+		return
+	}
+	staticNotFound(c)
+}
+
+func (s *staticServer) serveFile(c *Context, name string) {
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		staticNotFound(c)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil || fi.IsDir() {
+		staticNotFound(c)
+		return
+	}
+
+	// http.ServeContent需要Seek，不支持的文件读到内存中
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := ioutil.ReadAll(f)
+		if err != nil {
+			c.Fail(http.StatusInternalServerError, err.Error())
+			return
+		}
+		content = bytes.NewReader(data)
+	}
+	if !s.conf.DisableETag {
+		etag, err := s.etag(name, fi, content)
+		if err != nil {
+			c.Fail(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.SetHeader("ETag", etag)
+	}
+	if s.conf.MaxAge > 0 {
+		c.SetHeader("Cache-Control", "public, max-age="+strconv.FormatInt(int64(s.conf.MaxAge/time.Second), 10))
+	}
+	http.ServeContent(c.Writer, c.Req, fi.Name(), fi.ModTime(), content)
+}
+
+// 有修改时间时用 "修改时间-大小"，和nginx一样；embed.FS的文件没有修改时间，内容也不会变，按内容哈希并缓存
+func (s *staticServer) etag(name string, fi fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if !fi.ModTime().IsZero() {
+		return fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size()), nil
+	}
+	if etag, ok := s.etags.Load(name); ok {
+		return etag.(string), nil
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+	s.etags.Store(name, etag)
+	return etag, nil
+}
+
+var dirListTemplate = template.Must(template.New("dir").Parse(`<!doctype html>
+<meta name="viewport" content="width=device-width">
+<pre>
+{{range .}}<a href="{{.URL}}">{{.Name}}</a>
+{{end}}</pre>
+`))
+
+// 列出目录中的文件，子目录以/结尾
+func (s *staticServer) listDir(c *Context, name string) {
+	entries, err := fs.ReadDir(s.fsys, name)
+	if err != nil {
+		c.Fail(http.StatusInternalServerError, "error reading directory")
+		return
+	}
+	type item struct {
+		Name string
+		URL  string
+	}
+	items := make([]item, 0, len(entries))
+	for _, entry := range entries {
+		n := entry.Name()
+		if entry.IsDir() {
+			n += "/"
+		}
+		// 文件名中可能有 : 等字符，不能被当作URL的scheme
+		u := url.URL{Path: n}
+		items = append(items, item{Name: n, URL: "./" + u.EscapedPath()})
+	}
+	var buf bytes.Buffer
+	if err := dirListTemplate.Execute(&buf, items); err != nil {
+		c.Fail(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.SetHeader("Content-Type", MIMEHTML+"; charset=utf-8")
+	c.Data(http.StatusOK, buf.Bytes())
+}
+
+// 文件不存在时和没有匹配到路由一样，执行Engine.NoRoute设置的handler
+// 中间件已经在静态文件的路由上执行过了，这里只把NoRoute的handler接在后面
+func staticNotFound(c *Context) {
+	c.fullPath = ""
+	c.Status(http.StatusNotFound)
+	handlers := make([]HandlerFunc, 0, c.index+1+len(c.engine.noRoute))
+	handlers = append(handlers, c.handlers[:c.index+1]...)
+	c.handlers = append(handlers, c.engine.noRoute...)
+	c.Next()
+}
@@ -0,0 +1,136 @@
+package gee
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var staticFS = fstest.MapFS{
+	"index.html":  {Data: []byte("<h1>gee</h1>")},
+	"css/app.css": {Data: []byte("body { color: red; }")},
+	"js/app.js":   {Data: []byte("console.log('gee')"), ModTime: time.Unix(1600000000, 0)},
+}
+
+func TestStaticFS(t *testing.T) {
+	r := New()
+	assets := r.Group("/assets")
+	assets.Use(func(c *Context) {
+		c.SetHeader("X-Group", "assets")
+		c.Next()
+	})
+	assets.StaticFS("/", staticFS, StaticConfig{MaxAge: time.Hour})
+
+	w := performRequest(r, "GET", "/assets/css/app.css")
+	if w.Code != http.StatusOK || w.Body.String() != "body { color: red; }" || w.Header().Get("X-Group") != "assets" {
+		t.Fatalf("unexpected response %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/css") || w.Header().Get("Cache-Control") != "public, max-age=3600" {
+		t.Fatalf("unexpected headers %v", w.Header())
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("ETag should be generated from content")
+	}
+	if w := performRequest(r, "GET", "/assets/css/app.css", "If-None-Match", etag); w.Code != http.StatusNotModified {
+		t.Fatalf("status should be 304, got %d", w.Code)
+	}
+
+	w = performRequest(r, "GET", "/assets/js/app.js", "Range", "bytes=0-6")
+	if w.Code != http.StatusPartialContent || w.Body.String() != "console" || w.Header().Get("Last-Modified") == "" ||
+		w.Header().Get("ETag") != `"16345785d8a00000-12"` {
+		t.Fatalf("unexpected response %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+
+	cases := []struct {
+		path, location string
+		code           int
+		body           string
+	}{
+		{"/assets/", "", http.StatusOK, "<h1>gee</h1>"},
+		{"/assets/css?v=1", "/assets/css/?v=1", http.StatusMovedPermanently, ""},
+		{"/assets/css/", "", http.StatusNotFound, "404 NOT FOUND: /assets/css/\n"},
+		{"/assets/none.css", "", http.StatusNotFound, "404 NOT FOUND: /assets/none.css\n"},
+		{"/assets/../../etc/passwd", "", http.StatusNotFound, ""},
+	}
+	for _, tc := range cases {
+		w := performRequest(r, "GET", tc.path)
+		if w.Code != tc.code || w.Header().Get("Location") != tc.location || (tc.body != "" && w.Body.String() != tc.body) {
+			t.Fatalf("%s: unexpected response %d %q %v", tc.path, w.Code, w.Body.String(), w.Header())
+		}
+	}
+}
+
+func TestStaticBrowseAndSPA(t *testing.T) {
+	r := New()
+	r.StaticFS("/files", staticFS, StaticConfig{Browse: true, DisableETag: true})
+	r.StaticFS("/app", staticFS, StaticConfig{SPA: true})
+
+	w := performRequest(r, "GET", "/files/css/")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<a href="./app.css">app.css</a>`) || w.Header().Get("ETag") != "" {
+		t.Fatalf("unexpected listing %d %q", w.Code, w.Body.String())
+	}
+	if w := performRequest(r, "GET", "/app/users/1"); w.Code != http.StatusOK || w.Body.String() != "<h1>gee</h1>" {
+		t.Fatalf("SPA route should return index.html, got %d %q", w.Code, w.Body.String())
+	}
+	if w := performRequest(r, "GET", "/app/js/none.js"); w.Code != http.StatusNotFound {
+		t.Fatalf("missing asset should return 404, got %d", w.Code)
+	}
+}
+
+func TestStaticFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "robots.txt"), []byte("User-agent: *"), 0644)
+
+	r := New()
+	r.StaticFile("/robots.txt", filepath.Join(dir, "robots.txt"))
+	r.Static("/static", dir)
+	for _, path := range []string{"/robots.txt", "/static/robots.txt"} {
+		w := performRequest(r, "GET", path)
+		if w.Code != http.StatusOK || w.Body.String() != "User-agent: *" || w.Header().Get("ETag") == "" {
+			t.Fatalf("%s: unexpected response %d %q", path, w.Code, w.Body.String())
+		}
+	}
+	if w := performRequest(r, "GET", "/static/"); w.Code != http.StatusNotFound {
+		t.Fatalf("Static should not list directories, got %d", w.Code)
+	}
+}
+
+func TestStatic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "css"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte("<h1>gee</h1>"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "css", "app.css"), []byte("body{}"), 0644)
+
+	r := New()
+	r.Static("/assets", dir)
+
+	w := performRequest(r, "GET", "/assets/css/app.css")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || w.Body.String() != "body{}" || etag == "" {
+		t.Fatalf("unexpected response %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+	if w := performRequest(r, "GET", "/assets/css/app.css", "If-None-Match", etag); w.Code != http.StatusNotModified {
+		t.Fatalf("status should be 304, got %d", w.Code)
+	}
+	if w := performRequest(r, "GET", "/assets/"); w.Code != http.StatusOK || w.Body.String() != "<h1>gee</h1>" {
+		t.Fatalf("index.html should be served, got %d %q", w.Code, w.Body.String())
+	}
+	// 以前http.FileServer会列出目录，现在返回404
+	if w := performRequest(r, "GET", "/assets/css/"); w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "app.css") {
+		t.Fatalf("Static should not list directories, got %d %q", w.Code, w.Body.String())
+	}
+}
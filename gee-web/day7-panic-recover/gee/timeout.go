@@ -0,0 +1,192 @@
+package gee
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TimeoutConfig 是TimeoutWithConfig的配置
+type TimeoutConfig struct {
+	// 处理请求的最长时间
+	Timeout time.Duration
+	// 超时后的状态码，默认503
+	StatusCode int
+	// 超时后的错误信息，以 {"message": Message} 返回，默认 Service Unavailable
+	Message string
+}
+
+// Timeout 中间件，后面的handler超过d还没有完成时返回503
+func Timeout(d time.Duration) HandlerFunc {
+	return TimeoutWithConfig(TimeoutConfig{Timeout: d})
+}
+
+/*
+	TimeoutWithConfig 给请求的context加上截止时间，并在新的goroutine中执行后面的handler，
+	handler的输出先写到缓冲区，按时完成时再复制到真正的ResponseWriter。
+	超时后直接返回503，之后handler写入的内容都会被丢弃，所以两边不会同时写同一个ResponseWriter，
+	handler超时后的panic仍然会交给外层的Recovery记录。
+	Context是复用的，超时后仍然要等handler返回才能结束请求，
+	handler应该通过c.Done()或者把c作为context.Context传下去，及时响应取消
+*/
+func TimeoutWithConfig(conf TimeoutConfig) HandlerFunc {
+	if conf.StatusCode == 0 {
+		conf.StatusCode = http.StatusServiceUnavailable
+	}
+	if conf.Message == "" {
+		conf.Message = http.StatusText(conf.StatusCode)
+	}
+	body, _ := json.Marshal(H{"message": conf.Message})
+
+	return func(c *Context) {
+		ctx, cancel := context.WithTimeout(c.Req.Context(), conf.Timeout)
+		defer cancel()
+		c.Req = c.Req.WithContext(ctx)
+
+		w := c.Writer
+		tw := &timeoutWriter{header: w.Header().Clone(), status: defaultStatus, size: noWritten}
+		c.Writer = tw
+
+		done := make(chan struct{})
+		panicChan := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicChan <- p
+				}
+				close(done)
+			}()
+			c.Next()
+		}()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			tw.mu.Lock()
+			tw.timedOut = true
+			if tw.size == noWritten {
+				// 超时的响应已经发出，handler里的Written()也要返回true
+				tw.size = 0
+			}
+			tw.mu.Unlock()
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				w.Header().Set("Content-Type", MIMEJSON+"; charset=utf-8")
+				w.WriteHeader(conf.StatusCode)
+				w.Write(body)
+				w.Flush()
+			}
+			<-done
+		}
+		c.Writer = w
+
+		select {
+		case p := <-panicChan:
+			// 在当前goroutine中重新panic，让外层的Recovery处理。
+			// 超时后503已经发出，Recovery只会记录日志并中止
+			panic(p)
+		default:
+		}
+		if tw.timedOut {
+			c.Abort()
+			return
+		}
+		// handler删掉的头部也要从真正的响应中删掉
+		dst := w.Header()
+		for key := range dst {
+			if _, ok := tw.header[key]; !ok {
+				delete(dst, key)
+			}
+		}
+		for key, values := range tw.header {
+			dst[key] = values
+		}
+		w.WriteHeader(tw.status)
+		if tw.Written() {
+			w.Write(tw.body.Bytes())
+		}
+	}
+}
+
+// timeoutWriter 缓存handler的输出，超时之后的写入返回http.ErrHandlerTimeout
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	body     bytes.Buffer
+	status   int
+	size     int
+	timedOut bool
+}
+
+var _ ResponseWriter = &timeoutWriter{}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.size != noWritten || code <= 0 {
+		return
+	}
+	tw.status = code
+}
+
+func (tw *timeoutWriter) WriteHeaderNow() {
+	tw.mu.Lock()
+	if tw.size == noWritten {
+		tw.size = 0
+	}
+	tw.mu.Unlock()
+}
+
+func (tw *timeoutWriter) Write(data []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.size == noWritten {
+		tw.size = 0
+	}
+	n, err := tw.body.Write(data)
+	tw.size += n
+	return n, err
+}
+
+func (tw *timeoutWriter) WriteString(s string) (int, error) {
+	return tw.Write([]byte(s))
+}
+
+func (tw *timeoutWriter) Status() int {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.status
+}
+
+func (tw *timeoutWriter) Size() int {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.size
+}
+
+func (tw *timeoutWriter) Written() bool {
+	return tw.Size() != noWritten
+}
+
+// Flush 输出都在缓冲区中，handler完成之前不能发送
+func (tw *timeoutWriter) Flush() {}
+
+func (tw *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("gee: hijacking is not supported inside Timeout")
+}
+
+func (tw *timeoutWriter) Push(target string, opts *http.PushOptions) error {
+	return http.ErrNotSupported
+}
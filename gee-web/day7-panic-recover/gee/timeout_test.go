@@ -0,0 +1,74 @@
+package gee
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	r := New()
+	var logs bytes.Buffer
+	r.Use(RecoveryWithConfig(RecoveryConfig{Output: &logs}), func(c *Context) {
+		c.SetHeader("X-Outer", "outer")
+		c.Next()
+	}, Timeout(50*time.Millisecond))
+	r.GET("/slow", func(c *Context) {
+		select {
+		case <-c.Done():
+		case <-time.After(time.Second):
+		}
+		c.String(http.StatusOK, "too late")
+	})
+	r.GET("/fast", func(c *Context) {
+		if _, ok := c.Deadline(); !ok {
+			t.Error("context should have a deadline")
+		}
+		c.SetHeader("X-Gee", "fast")
+		c.Writer.Header().Del("X-Outer")
+		c.String(http.StatusCreated, "fast")
+	})
+	r.GET("/panic", func(c *Context) {
+		panic("boom")
+	})
+	r.GET("/late-panic", func(c *Context) {
+		<-c.Done()
+		panic("late boom")
+	})
+
+	w := httptest.NewRecorder()
+	start := time.Now()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != `{"message":"Service Unavailable"}` {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("handler should be canceled by the timeout")
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/fast", nil))
+	if w.Code != http.StatusCreated || w.Body.String() != "fast" || w.Header().Get("X-Gee") != "fast" ||
+		w.Header().Get("X-Outer") != "" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/panic", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("panic should be handled by Recovery, got %d", w.Code)
+	}
+
+	// 超时之后的panic不能被吞掉
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/late-panic", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status should be 503, got %d", w.Code)
+	}
+	if !strings.Contains(logs.String(), "late boom") {
+		t.Fatalf("panic after timeout should be logged, got %q", logs.String())
+	}
+}
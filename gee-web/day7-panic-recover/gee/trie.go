@@ -1,97 +1,267 @@
 package gee
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
+type nodeType uint8
+
+const (
+	static   nodeType = iota // 静态节点，path是压缩后的一段公共前缀
+	param                    // :参数节点，匹配到下一个 / 为止
+	catchAll                 // *通配节点，匹配剩余的全部路径
+)
+
+/*
+	压缩前缀树(radix tree)
+	静态部分按公共前缀合并成一个节点，例如 /hello/b/c 和 /hi/:name 合并出 /h 节点，
+	子节点再分别是 ello/b/c 和 i/，这样查找时一次比较一整段，不需要先把路径切成 []string。
+	静态子节点用 indices 记录首字节，查找时按首字节直接定位到对应的孩子。
+	:参数 和 *通配 子节点单独存放，*通配每一层最多一个，
+	:参数可以有多个约束不同的子节点，例如 :id<int> 和 :name。
+*/
 type node struct {
-	pattern  string  // 待匹配的路由，例如 /p/:lang
-	part     string  // 路由中的一部分，例如 :lang
-	children []*node // 子节点，例如 [doc, tutorial, intro]
-	isWild   bool    // 是否精确匹配，part 含有 : 或 * 时为true
+	path         string           // 静态节点是路径片段，例如 /hello/；通配节点是 :name、:id<int> 或 *filepath
+	key          string           // 通配节点的参数名，例如 name、id、filepath
+	constraint   *paramConstraint // :参数的约束，没有约束时为nil
+	pattern      string           // 待匹配的路由，例如 /p/:lang，只有注册过的节点才不为空
+	handlers     []HandlerFunc    // 路由对应的完整调用链，包括分组的中间件
+	indices      string           // 静态子节点path的首字节，和children一一对应
+	children     []*node          // 静态子节点
+	wildChildren []*node          // :参数子节点，有约束的在前，没有约束的最多一个，排在最后
+	catchAll     *node            // *通配子节点
+	nType        nodeType
 }
 
-/**
-第一个匹配成功的节点，用于插入
-part 代表请求路由的一部分，match的child表示匹配的节点，是树的一部分
-*/
-func (n *node) matchChild(part string) *node {
-	for _, child := range n.children {
-		// || 前一个表达式代表精确匹配到了路径，|| 后一个表达式代表模糊匹配，
-		// 模糊匹配的意思是，比如 路由的路径是/p/go
-		// p可以被精确匹配，匹配go时候进入新的节点node，也就是匹配到p的节点，
-		// go匹配n的孩子，如果n的孩子中有一个精确匹配到go或者它是模糊匹配，就可以返回这个孩子节点
-		// 然后可以继续递归查找下去
-		if child.part == part || child.isWild {
-			return child
+// segment 是pattern解析后的一段，静态的一段可以包含多个 /
+type segment struct {
+	nType nodeType
+	text  string
+}
+
+// 把pattern解析成静态片段和通配片段交替的序列
+// 例如 /p/:lang/doc 解析为 ["/p/", ":lang", "/doc"]
+func parseSegments(pattern string) []segment {
+	if pattern == "" || pattern[0] != '/' {
+		panic(fmt.Sprintf("gee: route '%s' must begin with '/'", pattern))
+	}
+	parts := parsePattern(pattern)
+	segments := make([]segment, 0, len(parts)+1)
+	text := "/"
+	for i, part := range parts {
+		switch part[0] {
+		case ':':
+			if len(part) == 1 {
+				panic(fmt.Sprintf("gee: wildcard in route '%s' must be named", pattern))
+			}
+			// 提前检查约束，写错时在注册时就panic
+			parseParam(part, pattern)
+			segments = append(segments, segment{static, text}, segment{param, part})
+			text = ""
+		case '*':
+			segments = append(segments, segment{static, text}, segment{catchAll, part})
+			text = ""
+		default:
+			text += part
+		}
+		// 通配 * 之后的部分会被parsePattern丢弃，所以*一定是最后一段
+		if part[0] != '*' && (i < len(parts)-1 || strings.HasSuffix(pattern, "/")) {
+			text += "/"
 		}
 	}
-	return nil
+	if text != "" {
+		segments = append(segments, segment{static, text})
+	}
+	return segments
+}
+
+// 两个字符串的最长公共前缀长度
+func longestCommonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
 }
 
 /*
-	所有匹配成功的节点，用于查找
-	part 代表传入的待匹配的路径的一部分，child代表可能匹配的节点，是树的一部分
+	把节点在第i个字节处拆成两个节点，前半段留在当前节点，
+	后半段连同原来的pattern和子节点一起下沉成为唯一的子节点
 */
-func (n *node) matchChildren(part string) []*node {
-	nodes := make([]*node, 0)
-	// 查找所有可能匹配的路径
-	for _, child := range n.children {
-		if child.part == part || child.isWild {
-			nodes = append(nodes, child)
-		}
+func (n *node) split(i int) {
+	child := &node{
+		path:         n.path[i:],
+		pattern:      n.pattern,
+		handlers:     n.handlers,
+		indices:      n.indices,
+		children:     n.children,
+		wildChildren: n.wildChildren,
+		catchAll:     n.catchAll,
+	}
+	*n = node{
+		path:     n.path[:i],
+		indices:  child.path[:1],
+		children: []*node{child},
+	}
+}
+
+// 取出*通配子节点，不存在时新建，名字不同视为冲突
+func (n *node) catchAllChild(seg segment, pattern string) *node {
+	if n.catchAll == nil {
+		n.catchAll = &node{path: seg.text, key: seg.text[1:], nType: catchAll}
+	} else if n.catchAll.path != seg.text {
+		panic(fmt.Sprintf("gee: wildcard '%s' in route '%s' conflicts with existing wildcard '%s'",
+			seg.text, pattern, n.catchAll.path))
 	}
-	return nodes
+	return n.catchAll
 }
 
 /*
-	递归查找每一层的节点，如果没有匹配到当前part的节点，则新建一个
+	取出:参数子节点，不存在时新建
+	约束相同(包括都没有约束)而名字不同时无法区分，视为冲突；约束不同的可以并存，
+	查找时按注册顺序尝试有约束的，最后尝试没有约束的
+*/
+func (n *node) paramChild(seg segment, pattern string) *node {
+	key, constraint := parseParam(seg.text, pattern)
+	for _, child := range n.wildChildren {
+		if child.constraint.String() != constraint.String() {
+			continue
+		}
+		if child.key != key {
+			panic(fmt.Sprintf("gee: wildcard '%s' in route '%s' conflicts with existing wildcard '%s'",
+				seg.text, pattern, child.path))
+		}
+		return child
+	}
+	child := &node{path: seg.text, key: key, constraint: constraint, nType: param}
+	last := len(n.wildChildren) - 1
+	if constraint != nil && last >= 0 && n.wildChildren[last].constraint == nil {
+		n.wildChildren = append(n.wildChildren[:last], child, n.wildChildren[last])
+	} else {
+		n.wildChildren = append(n.wildChildren, child)
+	}
+	return child
+}
 
-	插入是插入这个pattern，因此我们需要构造pattern对应的parts
-	然后从根节点("/")开始向下查找并插入pattern，所以每次都是找孩子和插入孩子
-	pattern 待匹配的路由路径
-	parts	pattern去除'/'的数组
-	height	当前节点的高度，即parts的下标，从0开始
+/*
+	递归插入pattern剩余的片段，当前节点自己的path已经匹配完
+	静态片段和已有孩子有公共前缀时，拆分孩子，在公共前缀的节点下继续插入
+	pattern  待注册的完整路由
+	segments pattern剩余未插入的片段
 */
-func (n *node) insert(pattern string, parts []string, height int) {
-	if len(parts) == height {
-		// 所给的路由路径最后一层都匹配完了
-		// 这里的pattern是全路径
-		// 只有在最后一层节点才会赋予pattern
+func (n *node) insert(pattern string, segments []segment, handlers []HandlerFunc) {
+	if len(segments) == 0 {
+		// 所给的路由路径都匹配完了，只有在最后一层节点才会赋予pattern
+		if n.pattern != "" {
+			panic(fmt.Sprintf("gee: route '%s' conflicts with existing route '%s'", pattern, n.pattern))
+		}
 		n.pattern = pattern
+		n.handlers = handlers
+		return
+	}
+
+	seg := segments[0]
+	switch seg.nType {
+	case param:
+		n.paramChild(seg, pattern).insert(pattern, segments[1:], handlers)
+		return
+	case catchAll:
+		n.catchAllChild(seg, pattern).insert(pattern, segments[1:], handlers)
 		return
 	}
 
-	part := parts[height]       // 当前的节点
-	child := n.matchChild(part) // 找是否匹配到这个part
-	if child == nil {           // 如果child为空，表示part不在这个trie树中，需要构造child
-		child = &node{part: part, isWild: part[0] == '*' || part[0] == ':'} // child不加pattern是因为pattern只在最后一层被赋值
-		n.children = append(n.children, child)
+	for i := 0; i < len(n.indices); i++ {
+		if n.indices[i] != seg.text[0] {
+			continue
+		}
+		child := n.children[i]
+		l := longestCommonPrefix(seg.text, child.path)
+		if l < len(child.path) {
+			child.split(l)
+		}
+		if l < len(seg.text) {
+			// 静态片段只匹配了一部分，剩下的部分继续往下插
+			segments = append([]segment{{static, seg.text[l:]}}, segments[1:]...)
+		} else {
+			segments = segments[1:]
+		}
+		child.insert(pattern, segments, handlers)
+		return
 	}
 
-	child.insert(pattern, parts, height+1)
+	// 没有公共前缀的孩子，新建一个静态子节点
+	child := &node{path: seg.text}
+	n.indices += seg.text[:1]
+	n.children = append(n.children, child)
+	child.insert(pattern, segments[1:], handlers)
 }
 
-// 从根节点出发向下递归查找路径
-func (n *node) search(parts []string, height int) *node {
-	// 匹配到了*，匹配失败，或者匹配到了第len(parts)层节点。
-	if len(parts) == height || strings.HasPrefix(n.part, "*") {
-		if n.pattern == "" { // 表示这个不是最后一个结点，而是中间结点
+/*
+	从根节点出发向下递归查找路径，匹配到的参数追加到params中
+	优先级：静态节点 > :参数 > *通配，与注册顺序无关，某个分支匹配失败时回溯到下一个候选
+	整个查找过程不切分path，参数值是path的子串，params容量足够时不会产生内存分配
+*/
+func (n *node) search(path string, params *Params) *node {
+	switch n.nType {
+	case static:
+		if len(path) < len(n.path) || path[:len(n.path)] != n.path {
+			return nil
+		}
+		path = path[len(n.path):]
+	case param:
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if end == 0 { // 参数不能为空
 			return nil
 		}
+		if n.constraint != nil && !n.constraint.match(path[:end]) {
+			return nil
+		}
+		*params = append(*params, Param{Key: n.key, Value: path[:end]})
+		path = path[end:]
+	case catchAll:
+		if n.key != "" {
+			*params = append(*params, Param{Key: n.key, Value: path})
+		}
 		return n
 	}
-	part := parts[height]
-	children := n.matchChildren(part) // 看trie树中是否能匹配这个part
 
-	for _, child := range children {
-		// 对当前的part可能匹配多个，只有最终匹配的会被返回result，即匹配到了第len(parts)层节点。否则都是nil
-		result := child.search(parts, height+1)
-		if result != nil {
+	if path == "" && n.pattern != "" {
+		return n
+	}
+	if path != "" {
+		c := path[0]
+		for i := 0; i < len(n.indices); i++ {
+			if n.indices[i] == c {
+				if result := n.children[i].search(path, params); result != nil {
+					return result
+				}
+				break
+			}
+		}
+	}
+	mark := len(*params)
+	if path != "" {
+		for _, child := range n.wildChildren {
+			if result := child.search(path, params); result != nil {
+				return result
+			}
+			*params = (*params)[:mark]
+		}
+	}
+	if n.catchAll != nil {
+		if result := n.catchAll.search(path, params); result != nil {
 			return result
 		}
+		*params = (*params)[:mark]
+	}
+	if n.nType == param {
+		*params = (*params)[:mark-1]
 	}
-
 	return nil
-
 }
 
 // 递归遍历child
@@ -102,4 +272,77 @@ func (n *node) travel(list *([]*node)) {
 	for _, child := range n.children {
 		child.travel(list)
 	}
+	for _, child := range n.wildChildren {
+		child.travel(list)
+	}
+	if n.catchAll != nil {
+		n.catchAll.travel(list)
+	}
+}
+
+/*
+	findCaseInsensitive 不区分大小写地查找path，找到时把树中注册的写法追加到buf返回，参数的值保持原样
+	fixTrailingSlash为true时，只差结尾的/也算找到，返回的路径按注册的路由加上或者去掉/
+*/
+func (n *node) findCaseInsensitive(path string, buf []byte, fixTrailingSlash bool) []byte {
+	switch n.nType {
+	case static:
+		if len(path) < len(n.path) || !strings.EqualFold(path[:len(n.path)], n.path) {
+			// 请求少了结尾的/，例如 /Hello 对应 /hello/
+			if fixTrailingSlash && n.pattern != "" && strings.EqualFold(path+"/", n.path) {
+				return append(buf, n.path...)
+			}
+			return nil
+		}
+		buf = append(buf, n.path...)
+		path = path[len(n.path):]
+	case param:
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if end == 0 || (n.constraint != nil && !n.constraint.match(path[:end])) {
+			return nil
+		}
+		buf = append(buf, path[:end]...)
+		path = path[end:]
+	case catchAll:
+		return append(buf, path...)
+	}
+
+	if path == "" && n.pattern != "" {
+		return buf
+	}
+	if path != "" {
+		// 首字节的大小写可能不同，两种写法的子节点都要尝试
+		for i := 0; i < len(n.indices); i++ {
+			if toLowerByte(n.indices[i]) == toLowerByte(path[0]) {
+				if result := n.children[i].findCaseInsensitive(path, buf, fixTrailingSlash); result != nil {
+					return result
+				}
+			}
+		}
+		for _, child := range n.wildChildren {
+			if result := child.findCaseInsensitive(path, buf, fixTrailingSlash); result != nil {
+				return result
+			}
+		}
+	}
+	if n.catchAll != nil {
+		if result := n.catchAll.findCaseInsensitive(path, buf, fixTrailingSlash); result != nil {
+			return result
+		}
+	}
+	// 请求多了结尾的/，例如 /Hello/ 对应 /hello
+	if fixTrailingSlash && path == "/" && n.pattern != "" {
+		return buf
+	}
+	return nil
+}
+
+func toLowerByte(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
 }
@@ -0,0 +1,117 @@
+package gee
+
+import "strings"
+
+/*
+	原来按 / 切分的Trie树，只用于和前缀树做性能对比
+	每次查找都要parsePattern切分路径、分配params map，每一层的matchChildren也会分配切片
+*/
+type legacyNode struct {
+	pattern  string
+	part     string
+	children []*legacyNode
+	isWild   bool
+}
+
+func (n *legacyNode) matchChild(part string) *legacyNode {
+	for _, child := range n.children {
+		if child.part == part {
+			return child
+		}
+	}
+	return nil
+}
+
+func (n *legacyNode) matchChildren(part string) []*legacyNode {
+	nodes := make([]*legacyNode, 0)
+	for _, child := range n.children {
+		if !child.isWild && child.part == part {
+			nodes = append(nodes, child)
+		}
+	}
+	for _, prefix := range []byte{':', '*'} {
+		for _, child := range n.children {
+			if child.isWild && child.part[0] == prefix {
+				nodes = append(nodes, child)
+			}
+		}
+	}
+	return nodes
+}
+
+func (n *legacyNode) insert(pattern string, parts []string, height int) {
+	if len(parts) == height {
+		n.pattern = pattern
+		return
+	}
+	part := parts[height]
+	child := n.matchChild(part)
+	if child == nil {
+		child = &legacyNode{part: part, isWild: isWildPart(part)}
+		n.children = append(n.children, child)
+	}
+	child.insert(pattern, parts, height+1)
+}
+
+func (n *legacyNode) search(parts []string, height int) *legacyNode {
+	if len(parts) == height || strings.HasPrefix(n.part, "*") {
+		if n.pattern == "" {
+			return nil
+		}
+		return n
+	}
+	for _, child := range n.matchChildren(parts[height]) {
+		if result := child.search(parts, height+1); result != nil {
+			return result
+		}
+	}
+	return nil
+}
+
+func isWildPart(part string) bool {
+	return part[0] == ':' || part[0] == '*'
+}
+
+type legacyRouter struct {
+	roots    map[string]*legacyNode
+	handlers map[string]HandlerFunc
+}
+
+func newLegacyRouter() *legacyRouter {
+	return &legacyRouter{
+		roots:    make(map[string]*legacyNode),
+		handlers: make(map[string]HandlerFunc),
+	}
+}
+
+func (r *legacyRouter) addRoute(method string, pattern string, handler HandlerFunc) {
+	if _, ok := r.roots[method]; !ok {
+		r.roots[method] = &legacyNode{}
+	}
+	r.roots[method].insert(pattern, parsePattern(pattern), 0)
+	r.handlers[method+"-"+pattern] = handler
+}
+
+// 和原来router.handle一样，查找路由之后还要拼接key取出handler
+func (r *legacyRouter) getRoute(method string, path string) (HandlerFunc, map[string]string) {
+	searchParts := parsePattern(path)
+	params := make(map[string]string)
+	root, ok := r.roots[method]
+	if !ok {
+		return nil, nil
+	}
+	n := root.search(searchParts, 0)
+	if n == nil {
+		return nil, nil
+	}
+	for index, part := range parsePattern(n.pattern) {
+		if part[0] == ':' {
+			params[part[1:]] = searchParts[index]
+		}
+		if part[0] == '*' && len(part) > 1 {
+			params[part[1:]] = strings.Join(searchParts[index:], "/")
+			break
+		}
+	}
+	return r.handlers[method+"-"+n.pattern], params
+}
@@ -0,0 +1,271 @@
+package gee
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+/*
+	声明式校验，规则写在binding标签中，多个规则用逗号分隔
+	required      不能是零值
+	min=n, max=n  数字比较大小，字符串比较字符数，切片和map比较长度
+	len=n         字符串的字符数、切片和map的长度，或者数字的值等于n
+	oneof=a b c   值必须是空格分隔的候选之一
+	regex=expr    字符串必须完整匹配正则，regex只能是最后一个规则，因为表达式里可以有逗号
+	没有required的字段为零值时，不检查其余的规则
+*/
+
+// FieldError 是一个字段的校验错误，可以直接通过Context.JSON返回给客户端
+type FieldError struct {
+	Field   string `json:"field"`           // 字段名，优先使用json、form、uri标签中的名字
+	Rule    string `json:"rule"`            // 没有通过的规则，例如 required
+	Param   string `json:"param,omitempty"` // 规则的参数，例如 min=3 中的 3
+	Message string `json:"message"`
+}
+
+func (fe FieldError) Error() string {
+	return fe.Message
+}
+
+// ValidationErrors 是一次校验中所有字段的错误
+type ValidationErrors []FieldError
+
+func (ve ValidationErrors) Error() string {
+	msgs := make([]string, len(ve))
+	for i, fe := range ve {
+		msgs[i] = fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate 按binding标签校验obj，obj可以是结构体或者结构体指针
+// 校验失败时返回ValidationErrors
+func Validate(obj interface{}) error {
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs ValidationErrors
+	validateStruct(v, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// 递归校验结构体，嵌套结构体和结构体切片的字段名带上路径，例如 address.city、items[0].name
+func validateStruct(v reflect.Value, prefix string, errs *ValidationErrors) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		fv := v.Field(i)
+		name := fieldName(field)
+		if field.Anonymous {
+			name = ""
+		}
+		if prefix != "" && name != "" {
+			name = prefix + "." + name
+		} else if name == "" {
+			name = prefix
+		}
+		if tag := field.Tag.Get("binding"); tag != "" && tag != "-" {
+			validateField(fv, name, tag, errs)
+		}
+		validateNested(fv, name, errs)
+	}
+}
+
+// 校验嵌套的结构体、结构体指针以及它们的切片
+func validateNested(fv reflect.Value, name string, errs *ValidationErrors) {
+	for fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return
+		}
+		fv = fv.Elem()
+	}
+	switch fv.Kind() {
+	case reflect.Struct:
+		if fv.Type() != timeType {
+			validateStruct(fv, name, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < fv.Len(); i++ {
+			validateNested(fv.Index(i), fmt.Sprintf("%s[%d]", name, i), errs)
+		}
+	}
+}
+
+// 错误中使用的字段名
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "uri"} {
+		name := field.Tag.Get(tag)
+		if i := strings.IndexByte(name, ','); i >= 0 {
+			name = name[:i]
+		}
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// 把binding标签拆成规则，regex之后的内容都属于正则表达式
+func parseRules(tag string) []string {
+	var rules []string
+	for tag != "" {
+		if strings.HasPrefix(tag, "regex=") {
+			return append(rules, tag)
+		}
+		i := strings.IndexByte(tag, ',')
+		if i < 0 {
+			return append(rules, tag)
+		}
+		if rule := strings.TrimSpace(tag[:i]); rule != "" {
+			rules = append(rules, rule)
+		}
+		tag = tag[i+1:]
+	}
+	return rules
+}
+
+func validateField(fv reflect.Value, name string, tag string, errs *ValidationErrors) {
+	rules := parseRules(tag)
+	required := false
+	for _, rule := range rules {
+		if rule == "required" {
+			required = true
+		}
+	}
+	zero := isZero(fv)
+	if zero && !required {
+		return
+	}
+	for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+		fv = fv.Elem()
+	}
+	for _, rule := range rules {
+		key, param := rule, ""
+		if i := strings.IndexByte(rule, '='); i >= 0 {
+			key, param = rule[:i], rule[i+1:]
+		}
+		var err error
+		if key == "required" {
+			if zero {
+				err = errors.New("is required")
+			}
+		} else {
+			err = checkRule(fv, key, param)
+		}
+		if err != nil {
+			*errs = append(*errs, FieldError{
+				Field:   name,
+				Rule:    key,
+				Param:   param,
+				Message: fmt.Sprintf("%s %s", name, err.Error()),
+			})
+			if key == "required" {
+				return
+			}
+		}
+	}
+}
+
+func isZero(fv reflect.Value) bool {
+	switch fv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return fv.IsNil()
+	case reflect.Slice, reflect.Map:
+		return fv.Len() == 0
+	}
+	return fv.IsZero()
+}
+
+// 检查一个规则，返回的错误是不带字段名的描述
+func checkRule(fv reflect.Value, key string, param string) error {
+	switch key {
+	case "min", "max", "len":
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			panic(fmt.Sprintf("gee: invalid %s parameter '%s'", key, param))
+		}
+		size, isNumber, ok := measure(fv)
+		if !ok {
+			panic(fmt.Sprintf("gee: rule '%s' does not support type %s", key, fv.Type()))
+		}
+		what := "length"
+		if isNumber {
+			what = "value"
+		}
+		switch {
+		case key == "min" && size < n:
+			return fmt.Errorf("%s must be at least %s", what, param)
+		case key == "max" && size > n:
+			return fmt.Errorf("%s must be at most %s", what, param)
+		case key == "len" && size != n:
+			return fmt.Errorf("%s must be %s", what, param)
+		}
+	case "oneof":
+		s := fmt.Sprint(fv.Interface())
+		for _, option := range strings.Fields(param) {
+			if s == option {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of [%s]", param)
+	case "regex":
+		if fv.Kind() != reflect.String {
+			panic(fmt.Sprintf("gee: rule 'regex' does not support type %s", fv.Type()))
+		}
+		if !compileRegex(param).MatchString(fv.String()) {
+			return fmt.Errorf("must match %s", param)
+		}
+	default:
+		panic(fmt.Sprintf("gee: unknown validation rule '%s'", key))
+	}
+	return nil
+}
+
+// 数字返回值本身，字符串返回字符数，切片和map返回长度
+func measure(fv reflect.Value) (size float64, isNumber bool, ok bool) {
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fv.Int()), true, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(fv.Uint()), true, true
+	case reflect.Float32, reflect.Float64:
+		return fv.Float(), true, true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(fv.String())), false, true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(fv.Len()), false, true
+	}
+	return 0, false, false
+}
+
+// 编译过的正则缓存起来，同一个结构体每次校验都会用到
+var regexCache sync.Map
+
+// 正则需要完整匹配字段的值
+func compileRegex(expr string) *regexp.Regexp {
+	if re, ok := regexCache.Load(expr); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile("^(?:" + expr + ")$")
+	regexCache.Store(expr, re)
+	return re
+}
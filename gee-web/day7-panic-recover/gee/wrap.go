@@ -0,0 +1,105 @@
+package gee
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+/*
+	和net/http互相转换，可以直接使用标准库和第三方的handler、中间件，例如
+	r.GET("/debug/pprof/*name", gee.WrapF(pprof.Index))
+	r.Use(gee.WrapMiddleware(handlers.ProxyHeaders))
+	r.Mount("/metrics", promhttp.Handler())
+*/
+
+// WrapF 把http.HandlerFunc转换成HandlerFunc
+func WrapF(f http.HandlerFunc) HandlerFunc {
+	return func(c *Context) {
+		f(c.Writer, c.Req)
+	}
+}
+
+// WrapH 把http.Handler转换成HandlerFunc
+func WrapH(h http.Handler) HandlerFunc {
+	return func(c *Context) {
+		h.ServeHTTP(c.Writer, c.Req)
+	}
+}
+
+/*
+	WrapMiddleware 把 func(http.Handler) http.Handler 形式的中间件转换成HandlerFunc
+	中间件调用next时继续执行后面的handler，没有调用时中止；
+	中间件传给next的请求和ResponseWriter在后面的handler中生效，返回之后恢复
+*/
+func WrapMiddleware(m func(http.Handler) http.Handler) HandlerFunc {
+	return func(c *Context) {
+		called := false
+		writer, req := c.Writer, c.Req
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			c.Req = r
+			if rw, ok := w.(ResponseWriter); ok {
+				c.Writer = rw
+			} else {
+				inner := &responseWriter{}
+				inner.reset(w)
+				c.Writer = inner
+			}
+			defer func() {
+				// 只设置了状态码的响应，在中间件返回之前把响应头写给它
+				c.Writer.WriteHeaderNow()
+				c.Writer, c.Req = writer, req
+			}()
+			c.Next()
+		})
+		m(next).ServeHTTP(c.Writer, c.Req)
+		if !called {
+			c.Abort()
+		}
+	}
+}
+
+/*
+	Mount 把http.Handler挂载到分组的prefix下，所有请求方法和prefix下的全部路径都交给它处理
+	请求的路径会去掉prefix，例如挂载在/admin下，/admin/users 在handler中看到的是 /users，
+	所以可以挂载另一个Engine作为子应用。分组的中间件会先执行
+*/
+func (group *RouterGroup) Mount(prefix string, h http.Handler) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	depth := len(parsePattern(group.prefix + prefix))
+	handler := func(c *Context) {
+		// 和http.StripPrefix一样，只复制请求和URL，不修改原来的请求
+		req := new(http.Request)
+		*req = *c.Req
+		req.URL = new(url.URL)
+		*req.URL = *c.Req.URL
+		req.URL.Path = stripSegments(req.URL.Path, depth)
+		if req.URL.RawPath != "" {
+			req.URL.RawPath = stripSegments(req.URL.RawPath, depth)
+		}
+		h.ServeHTTP(c.Writer, req)
+	}
+	for _, method := range anyMethods {
+		if group.prefix+prefix != "" {
+			group.addRoute(method, prefix, handler)
+		}
+		group.addRoute(method, prefix+"/*mountpath", handler)
+	}
+}
+
+// 去掉路径开头的n段，剩下的部分以/开头
+func stripSegments(p string, n int) string {
+	for i := 0; i < n; i++ {
+		p = strings.TrimLeft(p, "/")
+		end := strings.IndexByte(p, '/')
+		if end < 0 {
+			return "/"
+		}
+		p = p[end:]
+	}
+	if p == "" {
+		return "/"
+	}
+	return p
+}
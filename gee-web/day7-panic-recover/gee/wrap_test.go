@@ -0,0 +1,158 @@
+package gee
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWrapHandler(t *testing.T) {
+	r := New()
+	r.GET("/f", WrapF(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("f " + req.URL.Path))
+	}))
+	r.GET("/h", WrapH(http.NotFoundHandler()))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/f", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "f /f" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/h", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status %d", w.Code)
+	}
+}
+
+type ctxKey struct{}
+
+func TestWrapMiddleware(t *testing.T) {
+	r := New()
+	var trace []string
+	r.Use(func(c *Context) {
+		c.Next()
+		trace = append(trace, "after "+c.Req.Header.Get("X-Step"))
+	})
+	r.Use(WrapMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("deny") != "" {
+				http.Error(w, "denied", http.StatusForbidden)
+				return
+			}
+			req = req.WithContext(context.WithValue(req.Context(), ctxKey{}, "gee"))
+			req.Header.Set("X-Step", "wrapped")
+			w.Header().Set("X-Wrapped", "1")
+			// 包装ResponseWriter，后面的handler写入的内容要经过它
+			next.ServeHTTP(upperWriter{w}, req)
+		})
+	}))
+	r.GET("/hello", func(c *Context) {
+		trace = append(trace, "handler")
+		c.String(http.StatusCreated, "hello %s", c.Req.Context().Value(ctxKey{}))
+	})
+	r.GET("/status", func(c *Context) {
+		c.Status(http.StatusAccepted)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/hello", nil))
+	if w.Code != http.StatusCreated || w.Body.String() != "HELLO GEE" || w.Header().Get("X-Wrapped") != "1" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+	if strings.Join(trace, ",") != "handler,after wrapped" {
+		t.Fatalf("unexpected trace %v", trace)
+	}
+
+	trace = nil
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/hello?deny=1", nil))
+	if w.Code != http.StatusForbidden || strings.Join(trace, ",") != "after " {
+		t.Fatalf("handler shouldn't run when next is not called, got %d %v", w.Code, trace)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status without body should be sent, got %d", w.Code)
+	}
+}
+
+type upperWriter struct {
+	http.ResponseWriter
+}
+
+func (w upperWriter) Write(data []byte) (int, error) {
+	return w.ResponseWriter.Write([]byte(strings.ToUpper(string(data))))
+}
+
+func TestMount(t *testing.T) {
+	sub := New()
+	sub.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "sub index")
+	})
+	sub.GET("/users/:name", func(c *Context) {
+		c.String(http.StatusOK, "sub %s %s", c.Param("name"), c.Req.URL.RawPath)
+	})
+	sub.UseRawPath = true
+
+	r := New()
+	var trace []string
+	tenant := r.Group("/:tenant")
+	tenant.Use(func(c *Context) {
+		trace = append(trace, c.Param("tenant"))
+		c.Next()
+	})
+	tenant.Mount("/admin/", sub)
+	r.Mount("/echo", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(req.Method + " " + req.URL.Path))
+	}))
+
+	cases := []struct {
+		method, path string
+		body         string
+	}{
+		{"GET", "/acme/admin", "sub index"},
+		{"GET", "/acme/admin/", "sub index"},
+		{"GET", "/acme/admin/users/gee", "sub gee "},
+		{"GET", "/acme/admin/users/a%2Fb", "sub a/b /users/a%2Fb"},
+		{"DELETE", "/echo/a/b", "DELETE /a/b"},
+		{"POST", "/echo", "POST /"},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		path := req.URL.Path
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || w.Body.String() != tc.body {
+			t.Fatalf("%s %s: unexpected response %d %q", tc.method, tc.path, w.Code, w.Body.String())
+		}
+		if req.URL.Path != path {
+			t.Fatalf("original request shouldn't be modified, got %q", req.URL.Path)
+		}
+	}
+	if strings.Join(trace, ",") != "acme,acme,acme,acme" {
+		t.Fatalf("group middlewares should run before the mounted handler, got %v", trace)
+	}
+}
+
+func TestStripSegments(t *testing.T) {
+	cases := []struct {
+		path string
+		n    int
+		want string
+	}{
+		{"/a/b/c", 0, "/a/b/c"},
+		{"/a/b/c", 1, "/b/c"},
+		{"/a/b/c", 3, "/"},
+		{"/a/b/", 2, "/"},
+		{"/a", 1, "/"},
+	}
+	for _, tc := range cases {
+		if got := stripSegments(tc.path, tc.n); got != tc.want {
+			t.Fatalf("stripSegments(%q, %d) = %q, want %q", tc.path, tc.n, got, tc.want)
+		}
+	}
+}